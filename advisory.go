@@ -0,0 +1,60 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"hash/fnv"
+)
+
+// Postgres advisory lock helpers.  Errors are set on tx.Err like any other DB call.
+//
+// Prefer the transaction-scoped (Xact) locks.  They are released automatically when
+// the transaction commits or rolls back.  Session-level locks (AdvisoryLock) are held
+// by the underlying connection until AdvisoryUnlock is called, even after the
+// transaction ends and the connection is returned to the pool.
+
+// Returns a lock key for a name (64-bit FNV-1a hash), for use with the advisory
+// lock functions.
+func AdvisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// Waits for the transaction-scoped advisory lock (pg_advisory_xact_lock)
+func (tx *TxWrap) AdvisoryXactLock(key int64) {
+	tx.advisoryLockQuery(`SELECT pg_advisory_xact_lock($1)`, key)
+}
+
+// Tries to get the transaction-scoped advisory lock without waiting
+// (pg_try_advisory_xact_lock).  Returns true if the lock was acquired.
+func (tx *TxWrap) TryAdvisoryXactLock(key int64) bool {
+	return tx.GetBool(`SELECT pg_try_advisory_xact_lock($1)`, key)
+}
+
+// Waits for the session-level advisory lock (pg_advisory_lock).  Must be released
+// with AdvisoryUnlock (see above).
+func (tx *TxWrap) AdvisoryLock(key int64) {
+	tx.advisoryLockQuery(`SELECT pg_advisory_lock($1)`, key)
+}
+
+// Tries to get the session-level advisory lock without waiting (pg_try_advisory_lock).
+// Returns true if the lock was acquired.
+func (tx *TxWrap) TryAdvisoryLock(key int64) bool {
+	return tx.GetBool(`SELECT pg_try_advisory_lock($1)`, key)
+}
+
+// Releases a session-level advisory lock (pg_advisory_unlock).  Returns false
+// if the lock was not held.
+func (tx *TxWrap) AdvisoryUnlock(key int64) bool {
+	return tx.GetBool(`SELECT pg_advisory_unlock($1)`, key)
+}
+
+// runs a lock function that returns void.  runs as a query (not Exec) so the lock
+// is taken with WithDryRun and in read-only transactions, and with QueryRowx so it
+// is never memoized (WithMemoize).
+func (tx *TxWrap) advisoryLockQuery(query string, key int64) {
+	var rtn interface{}
+	tx.QueryRowx(query, key).Scan(&rtn)
+}
@@ -0,0 +1,126 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Records every DB call made in the transaction (statement, duration, rows affected,
+// error), including statements that were later rolled back to a savepoint.  Args are
+// redacted if a Redact function is set.  Once the transaction completes (committed or
+// not) 'fn' is called with the trail of the final attempt (if the transaction was
+// retried, the trails of the earlier attempts are discarded).  Also see AuditTrail
+// and WithAuditTable.
+func WithAuditTrail(fn func(ctx context.Context, trail []QueryInfo)) TxOption {
+	return func(opts *txOpts) {
+		opts.auditTrail = true
+		if fn != nil {
+			opts.auditFns = append(opts.auditFns, fn)
+		}
+	}
+}
+
+// Writes the audit trail (see WithAuditTrail) to 'table' inside of the transaction,
+// right before it commits, so the trail is committed (or rolled back) together with
+// the statements it describes.  If the insert fails the transaction is rolled back
+// and WithTx returns the error.  Not written for dry runs or read-only transactions.  The table name is not
+// quoted or escaped, it must not come from user input.  The table must have the
+// following columns (Postgres shown):
+//
+//	CREATE TABLE txwrap_audit (
+//	    tx_id varchar(32) NOT NULL,
+//	    seq int NOT NULL,
+//	    op varchar(64) NOT NULL,
+//	    query text NOT NULL,
+//	    args text NOT NULL, -- JSON array
+//	    duration_us bigint NOT NULL,
+//	    rows_affected bigint NOT NULL,
+//	    err text,
+//	    created_ts bigint NOT NULL, -- commit time (ms)
+//	    PRIMARY KEY (tx_id, seq)
+//	);
+func WithAuditTable(table string) TxOption {
+	return func(opts *txOpts) {
+		opts.auditTrail = true
+		opts.auditTable = table
+	}
+}
+
+// Same as WithTx, but also returns the audit trail (see WithAuditTrail).  If nested
+// inside of another transaction, the returned trail is empty (use AuditTrail on the
+// outer transaction).
+func WithTxAuditTrail(ctx context.Context, db *sqlx.DB, fn func(tx *TxWrap) error, opts ...TxOption) ([]QueryInfo, error) {
+	var rtn []QueryInfo
+	auditOpt := WithAuditTrail(func(ctx context.Context, trail []QueryInfo) {
+		rtn = trail
+	})
+	err := WithTx(ctx, db, fn, append(opts, auditOpt)...)
+	return rtn, err
+}
+
+// Returns the statements recorded so far in this transaction (nil unless
+// WithAuditTrail or WithAuditTable is set).  The returned slice must not be modified.
+func (tx *TxWrap) AuditTrail() []QueryInfo {
+	return tx.auditTrail
+}
+
+func (tx *TxWrap) isAuditTrail() bool {
+	// DBWrap has no transaction to audit (the trail would grow forever)
+	return tx.opts != nil && tx.opts.auditTrail && tx.Txx != nil
+}
+
+func (opts *txOpts) reportAuditTrail(ctx context.Context, tx *TxWrap) {
+	if tx == nil || len(opts.auditFns) == 0 {
+		return
+	}
+	for _, fn := range opts.auditFns {
+		fn(ctx, tx.auditTrail)
+	}
+}
+
+// inserts the audit trail into opts.auditTable.  the inserts run directly on the
+// transaction, so they are not part of the trail (and are not rewritten, rejected
+// as read-only, etc.).
+func (tx *TxWrap) writeAuditTrail() error {
+	if tx.opts.auditTable == "" || len(tx.auditTrail) == 0 || tx.isReadOnly() {
+		return nil
+	}
+	txId := makeTxId()
+	nowMs := tx.now().UnixMilli()
+	query := tx.Txx.Rebind(fmt.Sprintf(`INSERT INTO %s (tx_id, seq, op, query, args, duration_us, rows_affected, err, created_ts) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, tx.opts.auditTable))
+	for idx, info := range tx.auditTrail {
+		var errStr *string
+		if info.Err != nil {
+			str := info.Err.Error()
+			errStr = &str
+		}
+		_, err := tx.Txx.ExecContext(tx.ctx, query, txId, idx+1, info.Op, info.Query, auditArgsJson(info.Args), info.Duration.Microseconds(), info.RowsAffected, errStr, nowMs)
+		if err != nil {
+			return fmt.Errorf("txwrap writing audit trail to %s: %w", tx.opts.auditTable, err)
+		}
+	}
+	return nil
+}
+
+// args that cannot be marshaled as JSON are written as strings (%v)
+func auditArgsJson(args []interface{}) string {
+	if args == nil {
+		args = []interface{}{}
+	}
+	barr, err := json.Marshal(args)
+	if err == nil {
+		return string(barr)
+	}
+	strArgs := make([]string, len(args))
+	for idx, arg := range args {
+		strArgs[idx] = fmt.Sprintf("%v", arg)
+	}
+	barr, _ = json.Marshal(strArgs)
+	return string(barr)
+}
@@ -0,0 +1,105 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Set by ExecBatch and NamedExecBatch when the statement fails for one of the
+// arg sets.  Wraps the underlying error.
+type BatchError struct {
+	Index int // index of the failing arg set (or row)
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch statement #%d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Runs 'query' once for each set of args, using a single prepared statement (see
+// Preparex).  Stops at the first error, which is set on tx.Err as a *BatchError
+// with the index of the failing arg set.  Returns the total number of rows affected
+// (the count so far if there is an error).
+//
+//	tx.ExecBatch(`UPDATE items SET price = ? WHERE id = ?`, [][]interface{}{
+//	    {100, 1},
+//	    {250, 2},
+//	})
+func (tx *TxWrap) ExecBatch(query string, argSets [][]interface{}) int64 {
+	if tx.checkErr() || len(argSets) == 0 {
+		return 0
+	}
+	stmt := tx.Preparex(query)
+	defer stmt.Close()
+	if tx.Err != nil {
+		return 0
+	}
+	var total int64
+	for idx, args := range argSets {
+		if !stmt.execBatch(idx, args, &total) {
+			break
+		}
+	}
+	return total
+}
+
+// Same as ExecBatch, but 'rows' is a slice of structs (or maps) and 'query' uses
+// named parameters (like NamedExec).  Each row is bound with the same query, so
+// every row must have all of the named parameters.
+func (tx *TxWrap) NamedExecBatch(query string, rows interface{}) int64 {
+	if tx.checkErr() {
+		return 0
+	}
+	rowsVal := reflect.ValueOf(rows)
+	if rowsVal.Kind() != reflect.Slice && rowsVal.Kind() != reflect.Array {
+		tx.SetErr(fmt.Errorf("NamedExecBatch invalid rows type %T, must be a slice", rows))
+		return 0
+	}
+	if rowsVal.Len() == 0 {
+		return 0
+	}
+	var stmt *TxStmt
+	var total int64
+	for idx := 0; idx < rowsVal.Len(); idx++ {
+		boundQuery, args, err := tx.queryer().BindNamed(query, rowsVal.Index(idx).Interface())
+		if err != nil {
+			tx.setQueryErr("NamedExecBatch", query, nil, &BatchError{Index: idx, Err: err})
+			break
+		}
+		if stmt == nil {
+			stmt = tx.Preparex(boundQuery)
+			defer stmt.Close()
+			if tx.Err != nil {
+				break
+			}
+		}
+		if !stmt.execBatch(idx, args, &total) {
+			break
+		}
+	}
+	return total
+}
+
+// runs one statement of a batch, adds the rows affected to 'total'
+func (s *TxStmt) execBatch(idx int, args []interface{}, total *int64) bool {
+	result := s.Exec(args...)
+	if s.tx.Err == nil {
+		numRows, err := result.RowsAffected()
+		if err != nil {
+			s.tx.setQueryErr("ExecBatch", s.query, args, err)
+		}
+		*total += numRows
+	}
+	if s.tx.Err != nil {
+		s.tx.Err = &BatchError{Index: idx, Err: s.tx.Err}
+		return false
+	}
+	return true
+}
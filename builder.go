@@ -0,0 +1,56 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// A query builder, e.g. a squirrel (github.com/Masterminds/squirrel) builder.
+// The query is used as returned from ToSql, use the builder's placeholder format
+// for the driver (e.g. squirrel.Dollar for Postgres).
+type Sqlizer interface {
+	ToSql() (string, []interface{}, error)
+}
+
+// returns false (and sets tx.Err) if there is an error or the builder fails
+func (tx *TxWrap) buildQuery(b Sqlizer) (string, []interface{}, bool) {
+	if tx.checkErr() {
+		return "", nil, false
+	}
+	query, args, err := b.ToSql()
+	if err != nil {
+		tx.Err = fmt.Errorf("query builder: %w", err)
+		return "", nil, false
+	}
+	return query, args, true
+}
+
+// Runs Exec with the query and args from 'b'.  A ToSql error is set on tx.Err.
+func (tx *TxWrap) ExecBuilder(b Sqlizer) sql.Result {
+	query, args, ok := tx.buildQuery(b)
+	if !ok {
+		return errResult{tx.Err}
+	}
+	return tx.Exec(query, args...)
+}
+
+// Runs Get with the query and args from 'b'.  A ToSql error is set on tx.Err.
+func (tx *TxWrap) GetBuilder(dest interface{}, b Sqlizer) bool {
+	query, args, ok := tx.buildQuery(b)
+	if !ok {
+		return false
+	}
+	return tx.Get(dest, query, args...)
+}
+
+// Runs Select with the query and args from 'b'.  A ToSql error is set on tx.Err.
+func (tx *TxWrap) SelectBuilder(dest interface{}, b Sqlizer) {
+	query, args, ok := tx.buildQuery(b)
+	if !ok {
+		return
+	}
+	tx.Select(dest, query, args...)
+}
@@ -0,0 +1,159 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const DefaultBulkInsertChunkSize = 100
+
+// most drivers limit the number of bind parameters (SQLite defaults to 999 in older versions)
+const maxBulkInsertParams = 999
+
+// Inserts a slice of structs (or pointers to structs) into 'table' using multi-row
+// INSERT statements (DefaultBulkInsertChunkSize rows per statement).  Columns come
+// from the struct's db tags (see sqlx).  'table' and the column names are not
+// quoted or escaped, they must not come from user input.
+func (tx *TxWrap) BulkInsert(table string, rows interface{}) {
+	tx.BulkInsertChunked(table, rows, DefaultBulkInsertChunkSize)
+}
+
+// Same as BulkInsert, but inserts 'chunkSize' rows per statement.  The chunk size
+// is reduced if needed to keep each statement under 999 bind parameters.
+func (tx *TxWrap) BulkInsertChunked(table string, rows interface{}, chunkSize int) {
+	if tx.checkErr() {
+		return
+	}
+	rowsVal := reflect.ValueOf(rows)
+	if rowsVal.Kind() != reflect.Slice && rowsVal.Kind() != reflect.Array {
+		tx.Err = fmt.Errorf("BulkInsert invalid rows type %T, must be a slice", rows)
+		return
+	}
+	if rowsVal.Len() == 0 {
+		return
+	}
+	elemType := rowsVal.Type().Elem()
+	for elemType.Kind() == reflect.Pointer {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		tx.Err = fmt.Errorf("BulkInsert invalid rows type %T, must be a slice of structs", rows)
+		return
+	}
+	fields := getStructFields(tx.mapper(), elemType)
+	if len(fields) == 0 {
+		tx.Err = fmt.Errorf("BulkInsert type %v has no columns", elemType)
+		return
+	}
+	if chunkSize <= 0 {
+		chunkSize = DefaultBulkInsertChunkSize
+	}
+	if chunkSize*len(fields) > maxBulkInsertParams {
+		chunkSize = max(1, maxBulkInsertParams/len(fields))
+	}
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		columns[i] = f.Column
+	}
+	rowPlaceholder := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(fields)), ", ") + ")"
+	queryPrefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES ", table, strings.Join(columns, ", "))
+	for start := 0; start < rowsVal.Len() && tx.Err == nil; start += chunkSize {
+		end := min(start+chunkSize, rowsVal.Len())
+		var placeholders []string
+		args := make([]interface{}, 0, (end-start)*len(fields))
+		for i := start; i < end; i++ {
+			rowVal, err := structValue(rowsVal.Index(i).Interface())
+			if err != nil {
+				tx.Err = fmt.Errorf("BulkInsert row %d: %w", i, err)
+				return
+			}
+			for _, f := range fields {
+				args = append(args, f.value(rowVal))
+			}
+			placeholders = append(placeholders, rowPlaceholder)
+		}
+		query := queryPrefix + strings.Join(placeholders, ", ")
+		tx.Exec(tx.queryer().Rebind(query), args...)
+	}
+}
+
+// Bulk loads rows into 'table' using Postgres COPY FROM STDIN via the lib/pq
+// driver (pq implements COPY through a prepared "COPY ... FROM STDIN" statement).
+// Other drivers (including pgx through database/sql) do not support this, for native
+// pgx use txwrappgx.  Returns the number of rows copied (0 on error).
+func (tx *TxWrap) CopyFrom(table string, columns []string, rows [][]interface{}) int64 {
+	idx := 0
+	return tx.CopyFromFunc(table, columns, func() ([]interface{}, error) {
+		if idx >= len(rows) {
+			return nil, nil
+		}
+		idx++
+		return rows[idx-1], nil
+	})
+}
+
+// Same as CopyFrom, but streams rows from 'nextFn'.  'nextFn' returns the next row,
+// or a nil row when there are no more rows.  An error from 'nextFn' stops the copy
+// and is set on tx.Err.
+func (tx *TxWrap) CopyFromFunc(table string, columns []string, nextFn func() ([]interface{}, error)) int64 {
+	if tx.checkErr() {
+		return 0
+	}
+	if tx.Txx == nil {
+		tx.Err = fmt.Errorf("CopyFrom requires a transaction")
+		return 0
+	}
+	quotedCols := make([]string, len(columns))
+	for i, col := range columns {
+		quotedCols[i] = quoteIdentifier(col)
+	}
+	query := fmt.Sprintf("COPY %s (%s) FROM STDIN", quoteIdentifier(table), strings.Join(quotedCols, ", "))
+	startTs := tx.now()
+	numRows, err := tx.copyFrom(query, nextFn)
+	tx.observeQuery("CopyFrom", query, nil, startTs, err)
+	if err != nil {
+		tx.setQueryErr("CopyFrom", query, nil, err)
+		return 0
+	}
+	return numRows
+}
+
+func (tx *TxWrap) copyFrom(query string, nextFn func() ([]interface{}, error)) (int64, error) {
+	stmt, err := tx.Txx.PrepareContext(tx.ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	for {
+		row, err := nextFn()
+		if err != nil {
+			return 0, err
+		}
+		if row == nil {
+			break
+		}
+		_, err = stmt.ExecContext(tx.ctx, row...)
+		if err != nil {
+			return 0, err
+		}
+	}
+	// an Exec with no args flushes the copy
+	result, err := stmt.ExecContext(tx.ctx)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// quotes a Postgres identifier, "schema.table" is quoted as "schema"."table"
+func quoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
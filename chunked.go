@@ -0,0 +1,48 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+// Default chunk size for SelectChunked (used when chunkSize <= 0)
+const DefaultChunkSize = 1000
+
+// Runs a keyset-paginated query repeatedly and passes each chunk of rows to 'fn'
+// (all in the same transaction), so large tables can be processed without loading
+// every row with one Select.  The query must take the last key and the limit as
+// its final two arguments (after 'args') and must be ordered by the key:
+//
+//	txwrap.SelectChunked(tx, `SELECT * FROM jobs WHERE status = ? AND id > ? ORDER BY id LIMIT ?`,
+//	    500, 0, func(j Job) int64 { return j.Id }, func(chunk []Job) error {
+//	        ...
+//	    }, "pending")
+//
+// 'startKey' is passed as the key for the first chunk, after that 'keyFn' is called
+// on the last row of the previous chunk.  Stops when a chunk has fewer than
+// 'chunkSize' rows, when 'fn' returns an error (which is set on tx), or when there
+// is a DB error.  Rows are scanned like SelectGeneric.
+func SelectChunked[RT any, KT any](tx Tx, query string, chunkSize int, startKey KT, keyFn func(row RT) KT, fn func(chunk []RT) error, args ...interface{}) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	lastKey := startKey
+	chunkArgs := make([]interface{}, len(args)+2)
+	copy(chunkArgs, args)
+	for {
+		chunkArgs[len(args)] = lastKey
+		chunkArgs[len(args)+1] = chunkSize
+		var chunk []RT
+		tx.Select(&chunk, query, chunkArgs...)
+		if len(chunk) == 0 {
+			return
+		}
+		err := fn(chunk)
+		if err != nil {
+			tx.SetErr(err)
+			return
+		}
+		if len(chunk) < chunkSize {
+			return
+		}
+		lastKey = keyFn(chunk[len(chunk)-1])
+	}
+}
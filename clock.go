@@ -0,0 +1,75 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"time"
+)
+
+// Source of time for TxWrap: query and transaction durations (QueryHooks, TxHooks,
+// TxStats, the WithQueryLogger slow threshold), retry backoff, WithWatchdog, and the
+// audit table timestamps.  Replace it with WithClock to test time-dependent behavior
+// without real sleeps.  Context deadlines (WithTxTimeout, WithStatementTimeout)
+// always use the system clock.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer            // like time.NewTimer
+	AfterFunc(d time.Duration, f func()) Timer // like time.AfterFunc (Timer.Chan returns nil)
+}
+
+type Timer interface {
+	Chan() <-chan time.Time
+	Stop() bool
+}
+
+// The default Clock (package time)
+var SystemClock Clock = systemClock{}
+
+// Sets the Clock used by the transaction (SystemClock if not set)
+func WithClock(clock Clock) TxOption {
+	return func(opts *txOpts) {
+		opts.clock = clock
+	}
+}
+
+type systemClock struct{}
+
+type systemTimer struct {
+	timer *time.Timer
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{timer: time.NewTimer(d)}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{timer: time.AfterFunc(d, f)}
+}
+
+func (t systemTimer) Chan() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t systemTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+func (opts *txOpts) getClock() Clock {
+	if opts == nil || opts.clock == nil {
+		return SystemClock
+	}
+	return opts.clock
+}
+
+func (tx *TxWrap) now() time.Time {
+	return tx.opts.getClock().Now()
+}
+
+func (tx *TxWrap) since(ts time.Time) time.Duration {
+	return tx.now().Sub(ts)
+}
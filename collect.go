@@ -0,0 +1,53 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"errors"
+)
+
+// Collects errors instead of stopping at the first one.  After a DB call fails, the
+// following DB calls still run, and when 'fn' returns, all of the errors are joined
+// (errors.Join) into tx.Err, so WithTx returns every failure and still rolls back.
+// Use this to run a batch of independent statements and report all of the failures.
+//
+// While 'fn' runs, tx.Err only holds the error from the most recent failed call, use
+// tx.Errs() to get all of the errors so far.  A canceled context still stops all
+// DB calls.  Note that Postgres aborts the transaction after an error (later
+// statements fail with "current transaction is aborted"), so on Postgres run each
+// statement in WithSavepoint (the savepoint is rolled back, the error is still
+// collected).
+func WithCollectErrors() TxOption {
+	return func(opts *txOpts) {
+		opts.collectErrors = true
+	}
+}
+
+func (tx *TxWrap) isCollectErrors() bool {
+	return tx.opts != nil && tx.opts.collectErrors
+}
+
+// Returns all of the errors so far (in order).  Without WithCollectErrors this is
+// just tx.Err (or nil).
+func (tx *TxWrap) Errs() []error {
+	rtn := append([]error(nil), tx.errs...)
+	if tx.Err != nil {
+		rtn = append(rtn, tx.Err)
+	}
+	return rtn
+}
+
+// returns tx.Err joined with any collected errors
+func (tx *TxWrap) collectedErr() error {
+	if len(tx.errs) == 0 {
+		return tx.Err
+	}
+	return errors.Join(tx.Errs()...)
+}
+
+// moves the collected errors into tx.Err (called after 'fn' returns)
+func (tx *TxWrap) joinCollectedErrs() {
+	tx.Err = tx.collectedErr()
+	tx.errs = nil
+}
@@ -0,0 +1,328 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// Goroutine-safe adapter for a TxWrap (see TxWrap.Concurrent).  Every call holds a
+// lock, so statements are serialized (the underlying transaction is a single
+// connection, so they could not run in parallel anyway) and Err/SetErr are race-free.
+// The usual error handling applies: once any goroutine's call fails, all later calls
+// (from every goroutine) are skipped.
+//
+// All goroutines must finish before the WithTx callback returns (the transaction is
+// committed or rolled back when it returns).  Do not use the underlying TxWrap, or
+// call WithTx with Context(), from other goroutines.
+type ConcurrentTxWrap struct {
+	lock sync.Mutex
+	tx   *TxWrap
+}
+
+var _ Tx = (*ConcurrentTxWrap)(nil)
+
+// Returns a goroutine-safe adapter for tx.  Create it once and share it between
+// goroutines (multiple adapters for the same TxWrap do not share a lock).
+func (tx *TxWrap) Concurrent() *ConcurrentTxWrap {
+	return &ConcurrentTxWrap{tx: tx}
+}
+
+// Returns the first error (from a DB call or SetErr)
+func (c *ConcurrentTxWrap) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.Err
+}
+
+func (c *ConcurrentTxWrap) Context() context.Context {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.Context()
+}
+
+// Runs 'fn' with the lock held, so a sequence of statements (e.g. a read-modify-write)
+// runs without statements from other goroutines in between.  'fn' must use the
+// passed TxWrap (calling methods on the ConcurrentTxWrap from 'fn' will deadlock).
+func (c *ConcurrentTxWrap) Do(fn func(tx *TxWrap)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.tx.checkErr() {
+		return
+	}
+	fn(c.tx)
+}
+
+func (c *ConcurrentTxWrap) NamedExec(query string, arg interface{}) sql.Result {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.NamedExec(query, arg)
+}
+
+func (c *ConcurrentTxWrap) Exec(query string, args ...interface{}) sql.Result {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.Exec(query, args...)
+}
+
+func (c *ConcurrentTxWrap) ExecMany(statements []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tx.ExecMany(statements)
+}
+
+func (c *ConcurrentTxWrap) ExecAffected(query string, args ...interface{}) int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.ExecAffected(query, args...)
+}
+
+func (c *ConcurrentTxWrap) ExecInsertId(query string, args ...interface{}) int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.ExecInsertId(query, args...)
+}
+
+func (c *ConcurrentTxWrap) ExecExpect(expected int64, query string, args ...interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.ExecExpect(expected, query, args...)
+}
+
+func (c *ConcurrentTxWrap) Exists(query string, args ...interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.Exists(query, args...)
+}
+
+func (c *ConcurrentTxWrap) NamedExists(query string, arg interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.NamedExists(query, arg)
+}
+
+func (c *ConcurrentTxWrap) GetString(query string, args ...interface{}) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetString(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetStringOk(query string, args ...interface{}) (string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetStringOk(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetFloat64(query string, args ...interface{}) float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetFloat64(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetFloat64Ok(query string, args ...interface{}) (float64, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetFloat64Ok(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetByteArr(query string, args ...interface{}) []byte {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetByteArr(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetBytesOk(query string, args ...interface{}) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetBytesOk(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetBool(query string, args ...interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetBool(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetBoolOk(query string, args ...interface{}) (bool, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetBoolOk(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetTime(query string, args ...interface{}) time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetTime(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetNullTime(query string, args ...interface{}) (time.Time, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetNullTime(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetUUID(query string, args ...interface{}) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetUUID(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetUUIDOk(query string, args ...interface{}) (string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetUUIDOk(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetCount(query string, args ...interface{}) int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetCount(query, args...)
+}
+
+func (c *ConcurrentTxWrap) SelectStrings(query string, args ...interface{}) []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.SelectStrings(query, args...)
+}
+
+func (c *ConcurrentTxWrap) SelectInt64s(query string, args ...interface{}) []int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.SelectInt64s(query, args...)
+}
+
+func (c *ConcurrentTxWrap) SelectInts(query string, args ...interface{}) []int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.SelectInts(query, args...)
+}
+
+func (c *ConcurrentTxWrap) SelectBools(query string, args ...interface{}) []bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.SelectBools(query, args...)
+}
+
+func (c *ConcurrentTxWrap) SelectTimes(query string, args ...interface{}) []time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.SelectTimes(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetInt(query string, args ...interface{}) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetInt(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetIntOk(query string, args ...interface{}) (int, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetIntOk(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetInt64(query string, args ...interface{}) int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetInt64(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetInt64Ok(query string, args ...interface{}) (int64, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetInt64Ok(query, args...)
+}
+
+func (c *ConcurrentTxWrap) Get(dest interface{}, query string, args ...interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.Get(dest, query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetRequired(dest interface{}, query string, args ...interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetRequired(dest, query, args...)
+}
+
+func (c *ConcurrentTxWrap) Select(dest interface{}, query string, args ...interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tx.Select(dest, query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetIn(dest interface{}, query string, args ...interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetIn(dest, query, args...)
+}
+
+func (c *ConcurrentTxWrap) SelectIn(dest interface{}, query string, args ...interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tx.SelectIn(dest, query, args...)
+}
+
+func (c *ConcurrentTxWrap) ExecIn(query string, args ...interface{}) sql.Result {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.ExecIn(query, args...)
+}
+
+func (c *ConcurrentTxWrap) NamedGet(dest interface{}, query string, arg interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.NamedGet(dest, query, arg)
+}
+
+func (c *ConcurrentTxWrap) NamedSelect(dest interface{}, query string, arg interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tx.NamedSelect(dest, query, arg)
+}
+
+func (c *ConcurrentTxWrap) SelectMaps(query string, args ...interface{}) []map[string]interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.SelectMaps(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetMap(query string, args ...interface{}) map[string]interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetMap(query, args...)
+}
+
+func (c *ConcurrentTxWrap) GetMapOk(query string, args ...interface{}) (map[string]interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.GetMapOk(query, args...)
+}
+
+func (c *ConcurrentTxWrap) SelectOrderedMaps(query string, args ...interface{}) ([]string, []map[string]interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tx.SelectOrderedMaps(query, args...)
+}
+
+// Unlike Do, 'fn' runs without the lock held (so it can make calls on the
+// ConcurrentTxWrap).  The error returned from 'fn' is set with SetErr.
+func (c *ConcurrentTxWrap) Run(fn func() error) {
+	if c.Err() != nil {
+		return
+	}
+	err := fn()
+	if err != nil {
+		c.SetErr(err)
+	}
+}
+
+func (c *ConcurrentTxWrap) SetErr(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tx.SetErr(err)
+}
@@ -0,0 +1,45 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Checks out a single connection from 'db', runs 'fn' with it, and returns the
+// connection to the pool.  Use this for work that must stay on one physical
+// connection (session SET statements, temp tables), combined with WithTxConn to run
+// transactions on the connection:
+//
+//	err := txwrap.WithConn(ctx, db, func(conn *sqlx.Conn) error {
+//	    conn.ExecContext(ctx, `CREATE TEMP TABLE import_rows (...)`)
+//	    return txwrap.WithTxConn(ctx, conn, func(tx *txwrap.TxWrap) error {
+//	        ...
+//	    })
+//	})
+//
+// Session state set on the connection stays on it after it is returned to the pool,
+// so reset anything that should not leak to other users of the pool.
+func WithConn(ctx context.Context, db *sqlx.DB, fn func(conn *sqlx.Conn) error) error {
+	if db == nil {
+		return fmt.Errorf("invalid nil DB passed to WithConn")
+	}
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return fn(conn)
+}
+
+// Returns the connection the transaction was started on with WithTxConn (nil if the
+// transaction was started on a connection pool).  The underlying *sql.Conn is
+// conn.Conn (and conn.Raw gives access to the driver connection).  Do not run
+// statements directly on the connection while the transaction is open.
+func (tx *TxWrap) Conn() *sqlx.Conn {
+	return tx.conn
+}
@@ -0,0 +1,362 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Same helper API as TxWrap, but runs queries directly on a *sqlx.DB (no transaction).
+// Uses the same error handling: once a DB call fails, all future calls are skipped
+// and the first error is available from Err().  Each statement runs on its own
+// (autocommit), so nothing is rolled back on error.  DBWrap implements Tx (and Querier),
+// so repository code that takes a Tx can be used both inside and outside of a transaction.
+//
+// Like TxWrap, DBWrap is not thread-safe.
+type DBWrap struct {
+	DB *sqlx.DB
+
+	w TxWrap
+}
+
+var _ Tx = (*DBWrap)(nil)
+
+// Creates a DBWrap.  Query options (e.g. WithQueryHook, WithRedact) apply, transaction
+// options (TxHooks, retries) are ignored.  With WithStatementTimeout, call Close when
+// done with the DBWrap (WithDB does this automatically).
+func NewDBWrap(ctx context.Context, db *sqlx.DB, opts ...TxOption) *DBWrap {
+	dbw := &DBWrap{DB: db, w: TxWrap{ctx: ctx, ext: db, opts: makeTxOpts(opts), dbMapper: db.Mapper}}
+	dbw.w.setupSQLCommenter()
+	dbw.w.setupAutoRebind()
+	dbw.w.setupQueryRewrite()
+	dbw.w.setupStatementTimeout()
+	return dbw
+}
+
+// Runs 'fn' with a DBWrap and returns the first error (the DBWrap error or the
+// error returned from 'fn').
+func WithDB(ctx context.Context, db *sqlx.DB, fn func(dbw *DBWrap) error, opts ...TxOption) error {
+	if db == nil {
+		return fmt.Errorf("invalid nil DB passed to WithDB")
+	}
+	dbw := NewDBWrap(ctx, db, opts...)
+	defer dbw.Close()
+	fnErr := fn(dbw)
+	if fnErr != nil {
+		dbw.SetErr(fnErr)
+	}
+	return dbw.Err()
+}
+
+// Cancels the WithStatementTimeout contexts of queries that returned rows (a TxWrap
+// cancels these when the transaction ends, a DBWrap has no end).  Does not close the
+// DB.  Safe to call multiple times, and the DBWrap can still be used afterwards.
+func (dbw *DBWrap) Close() {
+	dbw.w.cancelStmtTimeouts()
+}
+
+// Returns the first error from a DB call (or SetErr)
+func (dbw *DBWrap) Err() error {
+	return dbw.w.collectedErr()
+}
+
+// Returns the DBWrap's Context.  Unlike TxWrap.Context(), this is *not* a TxWrap
+// Context, so calling WithTx with it will begin a new transaction.
+func (dbw *DBWrap) Context() context.Context {
+	return dbw.w.ctx
+}
+
+// Returns the WithSlog logger (or slog.Default()), see TxWrap.Logger
+func (dbw *DBWrap) Logger() *slog.Logger {
+	return dbw.w.Logger()
+}
+
+func (dbw *DBWrap) setContext(ctx context.Context) func() {
+	return dbw.w.setContext(ctx)
+}
+
+func (dbw *DBWrap) NamedExec(query string, arg interface{}) sql.Result {
+	return dbw.w.NamedExec(query, arg)
+}
+
+func (dbw *DBWrap) Exec(query string, args ...interface{}) sql.Result {
+	return dbw.w.Exec(query, args...)
+}
+
+func (dbw *DBWrap) ExecMany(statements []string) {
+	dbw.w.ExecMany(statements)
+}
+
+func (dbw *DBWrap) ExecScript(script string) {
+	dbw.w.ExecScript(script)
+}
+
+func (dbw *DBWrap) ExecBatch(query string, argSets [][]interface{}) int64 {
+	return dbw.w.ExecBatch(query, argSets)
+}
+
+func (dbw *DBWrap) NamedExecBatch(query string, rows interface{}) int64 {
+	return dbw.w.NamedExecBatch(query, rows)
+}
+
+func (dbw *DBWrap) ExecAffected(query string, args ...interface{}) int64 {
+	return dbw.w.ExecAffected(query, args...)
+}
+
+func (dbw *DBWrap) ExecInsertId(query string, args ...interface{}) int64 {
+	return dbw.w.ExecInsertId(query, args...)
+}
+
+func (dbw *DBWrap) ExecExpect(expected int64, query string, args ...interface{}) bool {
+	return dbw.w.ExecExpect(expected, query, args...)
+}
+
+func (dbw *DBWrap) UpdateVersioned(key VersionKey, set map[string]interface{}) bool {
+	return dbw.w.UpdateVersioned(key, set)
+}
+
+func (dbw *DBWrap) DeleteExpect(expected int64, query string, args ...interface{}) bool {
+	return dbw.w.DeleteExpect(expected, query, args...)
+}
+
+func (dbw *DBWrap) UpdateExpect(expected int64, query string, args ...interface{}) bool {
+	return dbw.w.UpdateExpect(expected, query, args...)
+}
+
+func (dbw *DBWrap) InsertStruct(table string, v interface{}, opts ...StructOption) int64 {
+	return dbw.w.InsertStruct(table, v, opts...)
+}
+
+func (dbw *DBWrap) UpdateStruct(table string, v interface{}, whereCols []string, opts ...StructOption) int64 {
+	return dbw.w.UpdateStruct(table, v, whereCols, opts...)
+}
+
+func (dbw *DBWrap) Upsert(table string, keyColumns []string, row interface{}) {
+	dbw.w.Upsert(table, keyColumns, row)
+}
+
+func (dbw *DBWrap) BulkInsert(table string, rows interface{}) {
+	dbw.w.BulkInsert(table, rows)
+}
+
+func (dbw *DBWrap) BulkInsertChunked(table string, rows interface{}, chunkSize int) {
+	dbw.w.BulkInsertChunked(table, rows, chunkSize)
+}
+
+func (dbw *DBWrap) Exists(query string, args ...interface{}) bool {
+	return dbw.w.Exists(query, args...)
+}
+
+func (dbw *DBWrap) NamedExists(query string, arg interface{}) bool {
+	return dbw.w.NamedExists(query, arg)
+}
+
+func (dbw *DBWrap) GetString(query string, args ...interface{}) string {
+	return dbw.w.GetString(query, args...)
+}
+
+func (dbw *DBWrap) GetStringOk(query string, args ...interface{}) (string, bool) {
+	return dbw.w.GetStringOk(query, args...)
+}
+
+func (dbw *DBWrap) GetFloat64(query string, args ...interface{}) float64 {
+	return dbw.w.GetFloat64(query, args...)
+}
+
+func (dbw *DBWrap) GetFloat64Ok(query string, args ...interface{}) (float64, bool) {
+	return dbw.w.GetFloat64Ok(query, args...)
+}
+
+func (dbw *DBWrap) GetByteArr(query string, args ...interface{}) []byte {
+	return dbw.w.GetByteArr(query, args...)
+}
+
+func (dbw *DBWrap) GetBytesOk(query string, args ...interface{}) ([]byte, bool) {
+	return dbw.w.GetBytesOk(query, args...)
+}
+
+func (dbw *DBWrap) GetBool(query string, args ...interface{}) bool {
+	return dbw.w.GetBool(query, args...)
+}
+
+func (dbw *DBWrap) GetBoolOk(query string, args ...interface{}) (bool, bool) {
+	return dbw.w.GetBoolOk(query, args...)
+}
+
+func (dbw *DBWrap) GetTime(query string, args ...interface{}) time.Time {
+	return dbw.w.GetTime(query, args...)
+}
+
+func (dbw *DBWrap) GetNullTime(query string, args ...interface{}) (time.Time, bool) {
+	return dbw.w.GetNullTime(query, args...)
+}
+
+func (dbw *DBWrap) GetUUID(query string, args ...interface{}) string {
+	return dbw.w.GetUUID(query, args...)
+}
+
+func (dbw *DBWrap) GetUUIDOk(query string, args ...interface{}) (string, bool) {
+	return dbw.w.GetUUIDOk(query, args...)
+}
+
+func (dbw *DBWrap) SelectStrings(query string, args ...interface{}) []string {
+	return dbw.w.SelectStrings(query, args...)
+}
+
+func (dbw *DBWrap) SelectInt64s(query string, args ...interface{}) []int64 {
+	return dbw.w.SelectInt64s(query, args...)
+}
+
+func (dbw *DBWrap) SelectInts(query string, args ...interface{}) []int {
+	return dbw.w.SelectInts(query, args...)
+}
+
+func (dbw *DBWrap) SelectBools(query string, args ...interface{}) []bool {
+	return dbw.w.SelectBools(query, args...)
+}
+
+func (dbw *DBWrap) SelectTimes(query string, args ...interface{}) []time.Time {
+	return dbw.w.SelectTimes(query, args...)
+}
+
+func (dbw *DBWrap) GetInt(query string, args ...interface{}) int {
+	return dbw.w.GetInt(query, args...)
+}
+
+func (dbw *DBWrap) GetIntOk(query string, args ...interface{}) (int, bool) {
+	return dbw.w.GetIntOk(query, args...)
+}
+
+func (dbw *DBWrap) GetInt64(query string, args ...interface{}) int64 {
+	return dbw.w.GetInt64(query, args...)
+}
+
+func (dbw *DBWrap) GetInt64Ok(query string, args ...interface{}) (int64, bool) {
+	return dbw.w.GetInt64Ok(query, args...)
+}
+
+func (dbw *DBWrap) GetCount(query string, args ...interface{}) int64 {
+	return dbw.w.GetCount(query, args...)
+}
+
+func (dbw *DBWrap) Get(dest interface{}, query string, args ...interface{}) bool {
+	return dbw.w.Get(dest, query, args...)
+}
+
+func (dbw *DBWrap) GetRequired(dest interface{}, query string, args ...interface{}) bool {
+	return dbw.w.GetRequired(dest, query, args...)
+}
+
+func (dbw *DBWrap) Select(dest interface{}, query string, args ...interface{}) {
+	dbw.w.Select(dest, query, args...)
+}
+
+func (dbw *DBWrap) GetJSON(dest interface{}, query string, args ...interface{}) bool {
+	return dbw.w.GetJSON(dest, query, args...)
+}
+
+func (dbw *DBWrap) SelectJSON(dest interface{}, query string, args ...interface{}) {
+	dbw.w.SelectJSON(dest, query, args...)
+}
+
+func (dbw *DBWrap) GetIn(dest interface{}, query string, args ...interface{}) bool {
+	return dbw.w.GetIn(dest, query, args...)
+}
+
+func (dbw *DBWrap) SelectIn(dest interface{}, query string, args ...interface{}) {
+	dbw.w.SelectIn(dest, query, args...)
+}
+
+func (dbw *DBWrap) ExecIn(query string, args ...interface{}) sql.Result {
+	return dbw.w.ExecIn(query, args...)
+}
+
+func (dbw *DBWrap) NamedGet(dest interface{}, query string, arg interface{}) bool {
+	return dbw.w.NamedGet(dest, query, arg)
+}
+
+func (dbw *DBWrap) NamedSelect(dest interface{}, query string, arg interface{}) {
+	dbw.w.NamedSelect(dest, query, arg)
+}
+
+// The caller must Close the returned rows (see TxWrap.NamedQuery)
+func (dbw *DBWrap) NamedQuery(query string, arg interface{}) *sqlx.Rows {
+	return dbw.w.NamedQuery(query, arg)
+}
+
+// TxRows are not closed automatically for a DBWrap, the caller must Close them
+func (dbw *DBWrap) Queryx(query string, args ...interface{}) *TxRows {
+	return dbw.w.Queryx(query, args...)
+}
+
+func (dbw *DBWrap) QueryRowx(query string, args ...interface{}) *TxRow {
+	return dbw.w.QueryRowx(query, args...)
+}
+
+// TxStmts are not closed automatically for a DBWrap, the caller must Close them
+func (dbw *DBWrap) Preparex(query string) *TxStmt {
+	return dbw.w.Preparex(query)
+}
+
+func (dbw *DBWrap) SelectFunc(query string, args []interface{}, fn func(rows *sqlx.Rows) error) {
+	dbw.w.SelectFunc(query, args, fn)
+}
+
+func (dbw *DBWrap) SelectMaps(query string, args ...interface{}) []map[string]interface{} {
+	return dbw.w.SelectMaps(query, args...)
+}
+
+func (dbw *DBWrap) SelectMapsFunc(query string, args []interface{}, fn func(m map[string]interface{}) error) {
+	dbw.w.SelectMapsFunc(query, args, fn)
+}
+
+func (dbw *DBWrap) GetMap(query string, args ...interface{}) map[string]interface{} {
+	return dbw.w.GetMap(query, args...)
+}
+
+func (dbw *DBWrap) GetMapOk(query string, args ...interface{}) (map[string]interface{}, bool) {
+	return dbw.w.GetMapOk(query, args...)
+}
+
+func (dbw *DBWrap) SelectOrderedMaps(query string, args ...interface{}) ([]string, []map[string]interface{}) {
+	return dbw.w.SelectOrderedMaps(query, args...)
+}
+
+func (dbw *DBWrap) ExecBuilder(b Sqlizer) sql.Result {
+	return dbw.w.ExecBuilder(b)
+}
+
+func (dbw *DBWrap) GetBuilder(dest interface{}, b Sqlizer) bool {
+	return dbw.w.GetBuilder(dest, b)
+}
+
+func (dbw *DBWrap) SelectBuilder(dest interface{}, b Sqlizer) {
+	dbw.w.SelectBuilder(dest, b)
+}
+
+func (dbw *DBWrap) Rebind(query string) string {
+	return dbw.w.Rebind(query)
+}
+
+func (dbw *DBWrap) Run(fn func() error) {
+	dbw.w.Run(fn)
+}
+
+func (dbw *DBWrap) SetErr(err error) {
+	dbw.w.SetErr(err)
+}
+
+func (dbw *DBWrap) ExecReturning(dest interface{}, query string, args ...interface{}) int64 {
+	return dbw.w.ExecReturning(dest, query, args...)
+}
+
+func (dbw *DBWrap) SelectReturning(dest interface{}, query string, args ...interface{}) int64 {
+	return dbw.w.SelectReturning(dest, query, args...)
+}
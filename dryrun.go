@@ -0,0 +1,64 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Passed to OnRollback callbacks when a WithDryRun transaction is rolled back
+var ErrDryRun = errors.New("dry run, transaction rolled back")
+
+// Dry-run mode.  Statements run with Exec (and everything built on it: NamedExec,
+// ExecAffected, BulkInsert, InsertStruct, etc.) are not executed, they are passed to
+// 'reportFn' (args are redacted if WithRedact is set).  They report 0 rows affected
+// and a LastInsertId of 0.  Reads (Get, Select, etc.) still run.  The transaction is
+// always rolled back, WithTx returns nil if there were no errors, OnCommit callbacks
+// are not run, and OnRollback callbacks are passed ErrDryRun.
+//
+// Writes that do not go through Exec (e.g. INSERT ... RETURNING run with Get, or
+// CopyFrom) still execute, but are rolled back.
+func WithDryRun(reportFn func(ctx context.Context, query string, args []interface{})) TxOption {
+	return func(opts *txOpts) {
+		opts.dryRun = reportFn
+	}
+}
+
+// wraps the query handle with WithDryRun (called when a TxWrap is created)
+func (tx *TxWrap) setupDryRun() {
+	if tx.opts == nil || tx.opts.dryRun == nil {
+		return
+	}
+	tx.ext = dryRunExt{ExtContext: tx.queryer(), opts: tx.opts}
+}
+
+// sqlx.ExtContext that reports Exec statements instead of running them
+type dryRunExt struct {
+	sqlx.ExtContext
+	opts *txOpts
+}
+
+func (d dryRunExt) unwrapExt(ctx context.Context, query string) (sqlx.ExtContext, string) {
+	return d.ExtContext, query
+}
+
+func (d dryRunExt) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	args = d.opts.redactArgs(args)
+	d.opts.dryRun(ctx, query, args)
+	return dryRunResult{}, nil
+}
+
+type dryRunResult struct{}
+
+func (dryRunResult) LastInsertId() (int64, error) {
+	return 0, nil
+}
+
+func (dryRunResult) RowsAffected() (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,213 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const maxQueryErrorQueryLen = 200
+const maxQueryErrorArgLen = 64
+
+// Error recorded on the TxWrap when the WithQueryErrors option is set.  Wraps the
+// original error (use errors.Is / errors.As) and adds the failing query.
+type QueryError struct {
+	Op    string // TxWrap method, e.g. "Exec", "Get"
+	Query string
+	Args  []interface{} // nil unless WithQueryErrors(true), redacted if WithRedact is set
+	Err   error
+}
+
+func (e *QueryError) Error() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "%s %q", e.Op, truncateStr(e.Query, maxQueryErrorQueryLen))
+	if len(e.Args) > 0 {
+		buf.WriteString(" args=[")
+		for idx, arg := range e.Args {
+			if idx > 0 {
+				buf.WriteString(" ")
+			}
+			buf.WriteString(truncateStr(fmt.Sprintf("%v", arg), maxQueryErrorArgLen))
+		}
+		buf.WriteString("]")
+	}
+	fmt.Fprintf(&buf, ": %v", e.Err)
+	return buf.String()
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// Set by ExecExpect when the number of affected rows does not match
+type AffectedRowsError struct {
+	Query    string
+	Expected int64
+	Actual   int64
+}
+
+func (e *AffectedRowsError) Error() string {
+	return fmt.Sprintf("expected %d row(s) affected, got %d, query %q", e.Expected, e.Actual, truncateStr(e.Query, maxQueryErrorQueryLen))
+}
+
+func truncateStr(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen] + "..."
+}
+
+// sets tx.Err for a failed DB call.  runs the OnError hooks, then wraps the error in
+// a *QueryError if WithQueryErrors is set.
+func (tx *TxWrap) setQueryErr(op string, query string, args []interface{}, err error) {
+	if tx.opts != nil {
+		for _, hook := range tx.opts.errorHooks {
+			// hooks cannot clear the error, the DB call still failed
+			if newErr := hook(tx.ctx, op, query, err); newErr != nil {
+				err = newErr
+			}
+		}
+	}
+	if tx.opts == nil || !tx.opts.queryErrors {
+		tx.Err = err
+		return
+	}
+	qerr := &QueryError{Op: op, Query: query, Err: err}
+	if tx.opts.queryErrorArgs {
+		qerr.Args = tx.opts.redactArgs(args)
+	}
+	tx.Err = qerr
+}
+
+// The error classification functions below work without importing any driver
+// packages.  They inspect the error chain for:
+//   - Postgres (pgx, lib/pq): SQLSTATE via a SQLState() method or a Code string field
+//   - MySQL (go-sql-driver/mysql): the Number field of *mysql.MySQLError
+//   - SQLite (mattn/go-sqlite3, modernc.org/sqlite): the extended result code via
+//     an ExtendedCode field or a Code() method
+
+// Returns true if 'err' is a unique (or primary key) constraint violation
+func IsUniqueViolation(err error) bool {
+	if getSQLState(err) == "23505" {
+		return true
+	}
+	if num, ok := getMySQLNumber(err); ok && (num == 1062 || num == 1586) {
+		return true
+	}
+	code, ok := getSQLiteCode(err)
+	return ok && (code == 2067 || code == 1555) // SQLITE_CONSTRAINT_UNIQUE, SQLITE_CONSTRAINT_PRIMARYKEY
+}
+
+// Returns true if 'err' is a foreign key constraint violation
+func IsForeignKeyViolation(err error) bool {
+	if getSQLState(err) == "23503" {
+		return true
+	}
+	if num, ok := getMySQLNumber(err); ok && (num == 1451 || num == 1452) {
+		return true
+	}
+	code, ok := getSQLiteCode(err)
+	return ok && code == 787 // SQLITE_CONSTRAINT_FOREIGNKEY
+}
+
+// Returns true if 'err' is a serialization failure (SQLSTATE 40001).  Note that
+// MySQL reports deadlocks with SQLSTATE 40001 as well.
+func IsSerializationFailure(err error) bool {
+	return getSQLState(err) == "40001"
+}
+
+// Returns true if 'err' is a deadlock (Postgres 40P01 deadlock_detected, or
+// MySQL ER_LOCK_DEADLOCK)
+func IsDeadlock(err error) bool {
+	if getSQLState(err) == "40P01" {
+		return true
+	}
+	num, ok := getMySQLNumber(err)
+	return ok && num == 1213
+}
+
+// extracts the SQLSTATE code from a driver error
+func getSQLState(err error) string {
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState()
+	}
+	// go-sql-driver/mysql (SQLState [5]byte)
+	f := findErrField(err, "SQLState", func(t reflect.Type) bool {
+		return t.Kind() == reflect.Array && t.Elem().Kind() == reflect.Uint8
+	})
+	if f.IsValid() {
+		state := make([]byte, f.Len())
+		for i := range state {
+			state[i] = byte(f.Index(i).Uint())
+		}
+		return string(state)
+	}
+	// lib/pq (Code ErrorCode)
+	f = findErrField(err, "Code", func(t reflect.Type) bool {
+		return t.Kind() == reflect.String
+	})
+	if f.IsValid() {
+		return f.String()
+	}
+	return ""
+}
+
+func getMySQLNumber(err error) (uint64, bool) {
+	f := findErrField(err, "Number", func(t reflect.Type) bool {
+		return t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64
+	})
+	if !f.IsValid() {
+		return 0, false
+	}
+	return f.Uint(), true
+}
+
+func getSQLiteCode(err error) (int64, bool) {
+	// modernc.org/sqlite
+	var codeErr interface{ Code() int }
+	if errors.As(err, &codeErr) {
+		return int64(codeErr.Code()), true
+	}
+	// mattn/go-sqlite3
+	f := findErrField(err, "ExtendedCode", func(t reflect.Type) bool {
+		return t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64
+	})
+	if !f.IsValid() {
+		return 0, false
+	}
+	return f.Int(), true
+}
+
+// walks the error chain looking for a struct error with field 'name' whose type matches
+func findErrField(err error, name string, typeOk func(t reflect.Type) bool) reflect.Value {
+	if err == nil {
+		return reflect.Value{}
+	}
+	v := reflect.ValueOf(err)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		f := v.FieldByName(name)
+		if f.IsValid() && typeOk(f.Type()) {
+			return f
+		}
+	}
+	switch uerr := err.(type) {
+	case interface{ Unwrap() error }:
+		return findErrField(uerr.Unwrap(), name, typeOk)
+	case interface{ Unwrap() []error }:
+		for _, e := range uerr.Unwrap() {
+			f := findErrField(e, name, typeOk)
+			if f.IsValid() {
+				return f
+			}
+		}
+	}
+	return reflect.Value{}
+}
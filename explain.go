@@ -0,0 +1,111 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Passed to the WithExplain callback
+type ExplainInfo struct {
+	Query string
+	Args  []interface{} // redacted if a Redact function is set
+	Plan  string        // one line per row returned by EXPLAIN
+	Err   error         // error running EXPLAIN (not set on the TxWrap)
+}
+
+// Debug option.  Before each query that returns rows (Get, Select, GetMap, etc.) runs,
+// runs EXPLAIN for it and passes the plan to 'fn'.  If 'analyze' is set, uses
+// EXPLAIN ANALYZE (Postgres, MySQL 8.0.18+), which runs each query twice, so only
+// use it with read-only queries.  SQLite uses EXPLAIN QUERY PLAN (analyze is ignored).
+//
+// This adds a round-trip to every query, it is intended for staging/debugging.
+// Note that on Postgres an EXPLAIN that fails aborts the transaction.
+func WithExplain(analyze bool, fn func(ctx context.Context, info ExplainInfo)) TxOption {
+	return func(opts *txOpts) {
+		opts.explain = fn
+		opts.explainAnalyze = analyze
+	}
+}
+
+// wraps the query handle with WithExplain (called when a TxWrap is created)
+func (tx *TxWrap) setupExplain() {
+	if tx.opts == nil || tx.opts.explain == nil {
+		return
+	}
+	tx.ext = explainExt{ExtContext: tx.queryer(), opts: tx.opts}
+}
+
+// sqlx.ExtContext that runs EXPLAIN before queries
+type explainExt struct {
+	sqlx.ExtContext
+	opts *txOpts
+}
+
+func (e explainExt) unwrapExt(ctx context.Context, query string) (sqlx.ExtContext, string) {
+	return e.ExtContext, query
+}
+
+func (e explainExt) explainPrefix() string {
+	if strings.HasPrefix(e.DriverName(), "sqlite") {
+		return "EXPLAIN QUERY PLAN "
+	}
+	if e.opts.explainAnalyze {
+		return "EXPLAIN ANALYZE "
+	}
+	return "EXPLAIN "
+}
+
+func (e explainExt) explain(ctx context.Context, query string, args []interface{}) {
+	info := ExplainInfo{Query: query, Args: e.opts.redactArgs(args)}
+	info.Plan, info.Err = e.getPlan(ctx, query, args)
+	e.opts.explain(ctx, info)
+}
+
+func (e explainExt) getPlan(ctx context.Context, query string, args []interface{}) (string, error) {
+	rows, err := e.ExtContext.QueryxContext(ctx, e.explainPrefix()+query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var lines []string
+	for rows.Next() {
+		cols, err := rows.SliceScan()
+		if err != nil {
+			return "", err
+		}
+		strs := make([]string, len(cols))
+		for i, col := range cols {
+			if barr, ok := col.([]byte); ok {
+				col = string(barr)
+			}
+			strs[i] = fmt.Sprint(col)
+		}
+		lines = append(lines, strings.Join(strs, " | "))
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (e explainExt) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	e.explain(ctx, query, args)
+	return e.ExtContext.QueryContext(ctx, query, args...)
+}
+
+func (e explainExt) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	e.explain(ctx, query, args)
+	return e.ExtContext.QueryxContext(ctx, query, args...)
+}
+
+func (e explainExt) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	e.explain(ctx, query, args)
+	return e.ExtContext.QueryRowxContext(ctx, query, args...)
+}
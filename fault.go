@@ -0,0 +1,170 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// An error returned by WithFaultInjection.  Classified like the driver error it
+// simulates: State is returned from SQLState (so IsSerializationFailure, IsDeadlock,
+// etc. work), and Err (if set) is matched by errors.Is.
+type InjectedFault struct {
+	Msg   string
+	State string // SQLSTATE, "" if none
+	Err   error  // wrapped error, nil if none
+}
+
+func (e *InjectedFault) Error() string {
+	return "txwrap injected fault: " + e.Msg
+}
+
+func (e *InjectedFault) SQLState() string {
+	return e.State
+}
+
+func (e *InjectedFault) Unwrap() error {
+	return e.Err
+}
+
+var (
+	FaultSerializationFailure = &InjectedFault{Msg: "serialization failure", State: "40001"}
+	FaultDeadlock             = &InjectedFault{Msg: "deadlock detected", State: "40P01"}
+	FaultConnectionLost       = &InjectedFault{Msg: "connection lost", Err: driver.ErrBadConn}
+	FaultTimeout              = &InjectedFault{Msg: "timeout", Err: context.DeadlineExceeded}
+)
+
+// Configures WithFaultInjection
+type FaultConfig struct {
+	StatementRate float64 // fraction of statements that fail (0 to 1)
+	CommitRate    float64 // fraction of commits that fail (0 to 1)
+
+	// Errors to fail with, one is picked at random for each fault (defaults to
+	// FaultSerializationFailure).  Any error can be used, not just InjectedFaults.
+	Errors []error
+
+	// Only inject faults into statements matching the query (optional)
+	Match func(query string) bool
+
+	// Returns a random number in [0, 1), set for deterministic tests (default rand.Float64)
+	Rand func() float64
+}
+
+// Chaos testing mode, makes a fraction of the statements (or commits) in the
+// transaction fail with one of cfg.Errors, to exercise retry and error handling
+// code without a misbehaving database.  A failed statement is not sent to the
+// database, it fails like a regular DB error (tx.Err is set, QueryHooks see the
+// error).  A failed commit rolls back the transaction and WithTx returns an error
+// wrapping ErrCommitFailed and the fault.  Savepoint statements are never failed.
+//
+//	err := txwrap.WithTx(ctx, db, fn, txwrap.WithRetries(3), txwrap.WithFaultInjection(txwrap.FaultConfig{
+//	    StatementRate: 0.1,
+//	    CommitRate:    0.1,
+//	    Errors:        []error{txwrap.FaultSerializationFailure, txwrap.FaultConnectionLost},
+//	}))
+//
+// Never enable this in production.
+func WithFaultInjection(cfg FaultConfig) TxOption {
+	return func(opts *txOpts) {
+		opts.faults = &cfg
+	}
+}
+
+func (cfg *FaultConfig) random() float64 {
+	if cfg.Rand != nil {
+		return cfg.Rand()
+	}
+	return rand.Float64()
+}
+
+// returns the error to fail with, or nil (rate is StatementRate or CommitRate)
+func (cfg *FaultConfig) roll(rate float64) error {
+	if rate <= 0 || cfg.random() >= rate {
+		return nil
+	}
+	if len(cfg.Errors) == 0 {
+		return FaultSerializationFailure
+	}
+	idx := int(cfg.random() * float64(len(cfg.Errors)))
+	return cfg.Errors[min(idx, len(cfg.Errors)-1)]
+}
+
+func (cfg *FaultConfig) statementFault(query string) error {
+	if cfg.Match != nil && !cfg.Match(query) {
+		return nil
+	}
+	return cfg.roll(cfg.StatementRate)
+}
+
+// wraps the query handle with WithFaultInjection (called when a TxWrap is created)
+func (tx *TxWrap) setupFaultInjection() {
+	if tx.opts == nil || tx.opts.faults == nil {
+		return
+	}
+	tx.faults = tx.opts.faults
+	tx.ext = faultExt{ExtContext: tx.queryer(), cfg: tx.faults}
+}
+
+// row queries call this instead of prepareStmt, since QueryRowxContext cannot
+// return an error (faults for row queries are injected here instead of in faultExt)
+func (tx *TxWrap) prepareRowStmt(query string) error {
+	if tx.faults != nil {
+		if err := tx.faults.statementFault(query); err != nil {
+			return err
+		}
+	}
+	return tx.prepareStmt(query)
+}
+
+// returns a commit fault (or nil)
+func (tx *TxWrap) commitFault() error {
+	if tx.faults == nil {
+		return nil
+	}
+	err := tx.faults.roll(tx.faults.CommitRate)
+	if err == nil {
+		return nil
+	}
+	if rbErr := tx.rollback(); rbErr != nil {
+		return fmt.Errorf("%w (%w)", err, rbErr)
+	}
+	return err
+}
+
+// sqlx.ExtContext that fails statements with injected faults
+type faultExt struct {
+	sqlx.ExtContext
+	cfg *FaultConfig
+}
+
+func (f faultExt) unwrapExt(ctx context.Context, query string) (sqlx.ExtContext, string) {
+	return f.ExtContext, query
+}
+
+func (f faultExt) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if err := f.cfg.statementFault(query); err != nil {
+		return nil, err
+	}
+	return f.ExtContext.ExecContext(ctx, query, args...)
+}
+
+func (f faultExt) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if err := f.cfg.statementFault(query); err != nil {
+		return nil, err
+	}
+	return f.ExtContext.QueryContext(ctx, query, args...)
+}
+
+func (f faultExt) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	if err := f.cfg.statementFault(query); err != nil {
+		return nil, err
+	}
+	return f.ExtContext.QueryxContext(ctx, query, args...)
+}
@@ -0,0 +1,124 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Information about a single DB call made through a TxWrap.  Passed to QueryHooks
+// after the call completes.
+type QueryInfo struct {
+	Op       string // TxWrap method, e.g. "Exec", "Get", "Select"
+	Query    string
+	Args     []interface{} // redacted if a Redact function is set
+	Duration time.Duration
+	Err      error // note that Get and GetMap will report sql.ErrNoRows here
+
+	RowsAffected int64 // only set for Exec and NamedExec, -1 if not available
+}
+
+// Called after every DB call made through a TxWrap.  The Context is the TxWrap
+// context, so it carries any values set on the Context passed to WithTx.
+type QueryHook func(ctx context.Context, info QueryInfo)
+
+// Information about a completed (outer) transaction.  Passed to TxHook end functions.
+type TxInfo struct {
+	Duration  time.Duration // total time, including all attempts and OnCommit callbacks
+	Attempts  int           // number of times the transaction was run (> 1 if retried)
+	Committed bool          // true if the transaction committed (even if an OnCommit callback failed)
+	Err       error         // final error returned from WithTx
+}
+
+// Called when WithTx starts a new (outer) transaction, before the transaction begins.
+// The returned Context is used for the transaction (it is the TxWrap context seen by
+// QueryHooks), which allows hooks to attach values such as a tracing span.  The
+// returned end function (may be nil) is called once the transaction has committed
+// or rolled back (including all retries and OnCommit callbacks).
+type TxHook func(ctx context.Context) (context.Context, func(info TxInfo))
+
+func (opts *txOpts) startTxHooks(ctx context.Context) (context.Context, func(info TxInfo)) {
+	if len(opts.txHooks) == 0 {
+		return ctx, func(TxInfo) {}
+	}
+	var endFns []func(TxInfo)
+	for _, hook := range opts.txHooks {
+		var endFn func(TxInfo)
+		ctx, endFn = hook(ctx)
+		if endFn != nil {
+			endFns = append(endFns, endFn)
+		}
+	}
+	return ctx, func(info TxInfo) {
+		for i := len(endFns) - 1; i >= 0; i-- {
+			endFns[i](info)
+		}
+	}
+}
+
+func (tx *TxWrap) hasQueryHooks() bool {
+	return tx.opts != nil && len(tx.opts.queryHooks) > 0 || tx.isAuditTrail()
+}
+
+func (tx *TxWrap) observeQuery(op string, query string, args []interface{}, startTs time.Time, err error) {
+	if tx.stats == nil && !tx.hasQueryHooks() {
+		return
+	}
+	dur := tx.since(startTs)
+	if tx.stats != nil {
+		tx.stats.addQuery(dur, -1)
+	}
+	if !tx.hasQueryHooks() {
+		return
+	}
+	tx.callQueryHooks(QueryInfo{
+		Op:       op,
+		Query:    query,
+		Args:     args,
+		Duration: dur,
+		Err:      err,
+
+		RowsAffected: -1,
+	})
+}
+
+func (tx *TxWrap) observeExec(op string, query string, args []interface{}, startTs time.Time, result sql.Result, err error) {
+	if tx.stats == nil && !tx.hasQueryHooks() {
+		return
+	}
+	dur := tx.since(startTs)
+	var rowsAffected int64 = -1
+	if err == nil && result != nil {
+		if n, raErr := result.RowsAffected(); raErr == nil {
+			rowsAffected = n
+		}
+	}
+	if tx.stats != nil {
+		tx.stats.addQuery(dur, rowsAffected)
+	}
+	if !tx.hasQueryHooks() {
+		return
+	}
+	tx.callQueryHooks(QueryInfo{
+		Op:       op,
+		Query:    query,
+		Args:     args,
+		Duration: dur,
+		Err:      err,
+
+		RowsAffected: rowsAffected,
+	})
+}
+
+func (tx *TxWrap) callQueryHooks(info QueryInfo) {
+	info.Args = tx.opts.redactArgs(info.Args)
+	if tx.isAuditTrail() {
+		tx.auditTrail = append(tx.auditTrail, info)
+	}
+	for _, hook := range tx.opts.queryHooks {
+		hook(tx.ctx, info)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+//go:build go1.23
+
+package txwrap
+
+import (
+	"iter"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Returns an iterator over the rows of a query, each row is scanned into an RT
+// (structs are scanned by db tags using StructScan, other types must be a single
+// column).  The rows are closed when the loop exits (including on break).  If there
+// is an error it is set on tx.Err and yielded once (with the zero value of RT) as
+// the final element.
+//
+//	for user, err := range txwrap.Rows[User](tx, `SELECT * FROM users`) {
+//	    ...
+//	}
+func Rows[RT any](tx *TxWrap, query string, args ...interface{}) iter.Seq2[RT, error] {
+	return func(yield func(RT, error) bool) {
+		var zero RT
+		if tx.checkErr() {
+			yield(zero, tx.Err)
+			return
+		}
+		startTs := tx.now()
+		err := tx.selectFunc(query, args, makeRowsYieldFn(yield))
+		if err == errStopIteration {
+			err = nil
+		}
+		tx.observeQuery("Rows", query, args, startTs, err)
+		if err != nil {
+			tx.setQueryErr("Rows", query, args, err)
+			yield(zero, tx.Err)
+		}
+	}
+}
+
+type stopIterationError struct{}
+
+func (stopIterationError) Error() string {
+	return "stop iteration"
+}
+
+var errStopIteration error = stopIterationError{}
+
+func makeRowsYieldFn[RT any](yield func(RT, error) bool) func(rows *sqlx.Rows) error {
+	structScan := isStructScanType(reflect.TypeOf((*RT)(nil)).Elem())
+	return func(rows *sqlx.Rows) error {
+		var rtn RT
+		var err error
+		if structScan {
+			err = rows.StructScan(&rtn)
+		} else {
+			err = rows.Scan(&rtn)
+		}
+		if err != nil {
+			return err
+		}
+		if !yield(rtn, nil) {
+			return errStopIteration
+		}
+		return nil
+	}
+}
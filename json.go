@@ -0,0 +1,61 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Scans a single JSON column (json, jsonb, text, or blob) and unmarshals it into
+// 'dest' (a pointer, as passed to json.Unmarshal).  Returns true if a row was found
+// with a non-NULL value.  For NULL or no rows 'dest' is left unchanged.  An unmarshal
+// error is set on tx.Err.
+func (tx *TxWrap) GetJSON(dest interface{}, query string, args ...interface{}) bool {
+	var rtnBytes []byte
+	found := tx.Get(&rtnBytes, query, args...)
+	if !found || rtnBytes == nil {
+		return false
+	}
+	err := json.Unmarshal(rtnBytes, dest)
+	if err != nil {
+		tx.Err = fmt.Errorf("GetJSON query %q: %w", query, err)
+		return false
+	}
+	return true
+}
+
+// Selects a single JSON column, unmarshaling each row into an element of 'dest'
+// (a pointer to a slice, e.g. *[]MyStruct or *[]*MyStruct).  NULL values produce
+// zero-value (or nil) elements.  An unmarshal error is set on tx.Err (and 'dest'
+// is not modified).
+func (tx *TxWrap) SelectJSON(dest interface{}, query string, args ...interface{}) {
+	if tx.checkErr() {
+		return
+	}
+	destVal := reflect.ValueOf(dest)
+	if destVal.Kind() != reflect.Pointer || destVal.IsNil() || destVal.Elem().Kind() != reflect.Slice {
+		tx.Err = fmt.Errorf("SelectJSON dest must be a pointer to a slice, got %T", dest)
+		return
+	}
+	var rows [][]byte
+	tx.Select(&rows, query, args...)
+	if tx.Err != nil {
+		return
+	}
+	sliceVal := destVal.Elem()
+	rtn := reflect.MakeSlice(sliceVal.Type(), len(rows), len(rows))
+	for idx, rowBytes := range rows {
+		if rowBytes == nil {
+			continue
+		}
+		err := json.Unmarshal(rowBytes, rtn.Index(idx).Addr().Interface())
+		if err != nil {
+			tx.Err = fmt.Errorf("SelectJSON query %q row #%d: %w", query, idx, err)
+			return
+		}
+	}
+	sliceVal.Set(rtn)
+}
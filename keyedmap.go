@@ -0,0 +1,91 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Controls what SelectKeyedMap and SelectPairMap do when two rows have the same key
+type DuplicateKeys int
+
+const (
+	DuplicateKeysError     DuplicateKeys = iota // set a *DuplicateKeyError on tx (and return nil)
+	DuplicateKeysKeepFirst                      // keep the first row for the key
+	DuplicateKeysKeepLast                       // keep the last row for the key
+)
+
+// Set by SelectKeyedMap and SelectPairMap for a duplicate key (with DuplicateKeysError)
+type DuplicateKeyError struct {
+	Query string
+	Key   interface{}
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("duplicate key %v, query %q", e.Key, truncateStr(e.Query, maxQueryErrorQueryLen))
+}
+
+// Selects rows (like SelectGeneric) and returns them as a map, keyed by 'keyFn'.
+// Use this to load a lookup table in one call:
+//
+//	usersById := txwrap.SelectKeyedMap(tx, func(u User) int64 { return u.Id },
+//	    txwrap.DuplicateKeysError, `SELECT * FROM users WHERE org_id = ?`, orgId)
+//
+// Returns nil if there is a duplicate key (with DuplicateKeysError).  If the query
+// fails the error is set on tx and the map is empty.
+func SelectKeyedMap[K comparable, V any](tx Tx, keyFn func(row V) K, dup DuplicateKeys, query string, args ...interface{}) map[K]V {
+	var rows []V
+	tx.Select(&rows, query, args...)
+	rtn := make(map[K]V, len(rows))
+	for _, row := range rows {
+		err := addKeyedRow(rtn, keyFn(row), row, dup, query)
+		if err != nil {
+			tx.SetErr(err)
+			return nil
+		}
+	}
+	return rtn
+}
+
+// Runs a two column query and returns a map of the first column to the second:
+//
+//	namesById := txwrap.SelectPairMap[int64, string](tx, txwrap.DuplicateKeysError,
+//	    `SELECT id, name FROM users`)
+//
+// Returns nil if there is an error (including a duplicate key with DuplicateKeysError).
+func SelectPairMap[K comparable, V any](tx *TxWrap, dup DuplicateKeys, query string, args ...interface{}) map[K]V {
+	if tx.checkErr() {
+		return nil
+	}
+	rtn := make(map[K]V)
+	tx.SelectFunc(query, args, func(rows *sqlx.Rows) error {
+		var key K
+		var val V
+		err := rows.Scan(&key, &val)
+		if err != nil {
+			return err
+		}
+		return addKeyedRow(rtn, key, val, dup, query)
+	})
+	if tx.Err != nil {
+		return nil
+	}
+	return rtn
+}
+
+func addKeyedRow[K comparable, V any](m map[K]V, key K, val V, dup DuplicateKeys, query string) error {
+	if _, found := m[key]; found {
+		switch dup {
+		case DuplicateKeysKeepFirst:
+			return nil
+		case DuplicateKeysKeepLast:
+		default:
+			return &DuplicateKeyError{Query: query, Key: key}
+		}
+	}
+	m[key] = val
+	return nil
+}
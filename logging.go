@@ -0,0 +1,40 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Logs queries via 'logFn'.  Only queries that take at least 'slowThreshold' are
+// logged (a threshold of 0 logs every query).  Queries that fail are always logged
+// (sql.ErrNoRows is not considered a failure).  Args are redacted if WithRedact is set.
+func WithQueryLogger(slowThreshold time.Duration, logFn QueryHook) TxOption {
+	return WithQueryHook(func(ctx context.Context, info QueryInfo) {
+		isErr := info.Err != nil && !errors.Is(info.Err, sql.ErrNoRows)
+		if !isErr && info.Duration < slowThreshold {
+			return
+		}
+		logFn(ctx, info)
+	})
+}
+
+// Formats the QueryInfo as a single log line
+func (info QueryInfo) String() string {
+	rtn := fmt.Sprintf("%s (%v) %q", info.Op, info.Duration, info.Query)
+	if len(info.Args) > 0 {
+		rtn += fmt.Sprintf(" args=%v", info.Args)
+	}
+	if info.RowsAffected >= 0 {
+		rtn += fmt.Sprintf(" rows=%d", info.RowsAffected)
+	}
+	if info.Err != nil {
+		rtn += fmt.Sprintf(" err=%v", info.Err)
+	}
+	return rtn
+}
@@ -0,0 +1,171 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Memoizes Get and Select (and the typed getters built on Get, e.g. GetString,
+// GetInt64) for SELECT queries: a repeated call with the same query, args, and
+// destination type returns the cached result without running the query again.
+// The cache belongs to the transaction and is cleared by every Exec (or query that
+// is not a SELECT, e.g. INSERT ... RETURNING) and by RollbackTo, so reads after a
+// write see the write.  Writes made directly on the underlying *sqlx.Tx are not
+// seen, call ClearMemo after them.  Only applies to transactions started by WithTx.
+//
+// Only use for queries with stable results within the transaction.  Queries whose
+// result changes on every call (nextval, random, clock_timestamp, ...) must be run
+// with QueryRowx (never memoized) or after ClearMemo.  Cached Select results are
+// shallow copies, elements that contain pointers, maps, or slices share them with
+// the earlier result.
+func WithMemoize() TxOption {
+	return func(opts *txOpts) {
+		opts.memoize = true
+	}
+}
+
+// Clears the memoized results (see WithMemoize)
+func (tx *TxWrap) ClearMemo() {
+	tx.memo = nil
+}
+
+// wraps the query handle with WithMemoize (called when a TxWrap is created)
+func (tx *TxWrap) setupMemoize() {
+	if tx.opts == nil || !tx.opts.memoize {
+		return
+	}
+	tx.memoOn = true
+	tx.ext = memoExt{ExtContext: tx.queryer(), tx: tx}
+}
+
+type memoEntry struct {
+	val   reflect.Value // copy of *dest (invalid if no row was found)
+	found bool
+}
+
+// only plain SELECT queries are memoized (not WITH, which can contain writes)
+func isMemoQuery(query string) bool {
+	query = strings.TrimSpace(query)
+	return len(query) >= 6 && strings.EqualFold(query[:6], "select")
+}
+
+// returns "" if the call cannot be memoized
+func (tx *TxWrap) memoKey(op string, dest interface{}, query string, args []interface{}) string {
+	if !tx.memoOn || !isMemoQuery(query) {
+		return ""
+	}
+	destType := reflect.TypeOf(dest)
+	if destType == nil || destType.Kind() != reflect.Pointer {
+		return ""
+	}
+	return fmt.Sprintf("%s\x00%v\x00%s\x00%#v", op, destType, query, memoKeyArgs(args))
+}
+
+// returns the args as they are sent to the database, for the key.  %#v would print
+// pointers as addresses (a reused pointer to a changed value would hit a stale
+// entry) and Redact values as RedactedPlaceholder (different redacted values would
+// share an entry).
+func memoKeyArgs(args []interface{}) []interface{} {
+	rtn := make([]interface{}, len(args))
+	for idx, arg := range args {
+		if redacted, ok := arg.(RedactedArg); ok {
+			arg = redacted.Arg
+		}
+		// derefs pointers and calls driver.Valuer
+		if val, err := driver.DefaultParameterConverter.ConvertValue(arg); err == nil {
+			rtn[idx] = val
+			continue
+		}
+		// not a driver.Value (e.g. a slice for pgx), deref pointers for %#v
+		rv := reflect.ValueOf(arg)
+		for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+			rv = rv.Elem()
+		}
+		if rv.Kind() == reflect.Pointer || !rv.IsValid() {
+			rtn[idx] = nil
+		} else {
+			rtn[idx] = rv.Interface()
+		}
+	}
+	return rtn
+}
+
+// on a hit, copies the cached value to dest
+func (tx *TxWrap) memoLookup(key string, dest interface{}) (bool, bool) {
+	if key == "" {
+		return false, false
+	}
+	entry, ok := tx.memo[key]
+	if !ok {
+		return false, false
+	}
+	if entry.found {
+		reflect.ValueOf(dest).Elem().Set(entry.val)
+	}
+	return entry.found, true
+}
+
+func (tx *TxWrap) memoStore(key string, dest interface{}, found bool) {
+	if key == "" {
+		return
+	}
+	entry := memoEntry{found: found}
+	if found {
+		destVal := reflect.ValueOf(dest).Elem()
+		entry.val = reflect.New(destVal.Type()).Elem()
+		entry.val.Set(destVal)
+		if destVal.Kind() == reflect.Slice {
+			// so appending to the returned slice does not change the cached value
+			entry.val.Set(reflect.AppendSlice(reflect.MakeSlice(destVal.Type(), 0, destVal.Len()), destVal))
+		}
+	}
+	if tx.memo == nil {
+		tx.memo = make(map[string]memoEntry)
+	}
+	tx.memo[key] = entry
+}
+
+// sqlx.ExtContext that clears the memo cache on writes
+type memoExt struct {
+	sqlx.ExtContext
+	tx *TxWrap
+}
+
+func (m memoExt) unwrapExt(ctx context.Context, query string) (sqlx.ExtContext, string) {
+	return m.ExtContext, query
+}
+
+func (m memoExt) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	m.tx.ClearMemo()
+	return m.ExtContext.ExecContext(ctx, query, args...)
+}
+
+func (m memoExt) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	m.clearIfWrite(query)
+	return m.ExtContext.QueryContext(ctx, query, args...)
+}
+
+func (m memoExt) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	m.clearIfWrite(query)
+	return m.ExtContext.QueryxContext(ctx, query, args...)
+}
+
+func (m memoExt) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	m.clearIfWrite(query)
+	return m.ExtContext.QueryRowxContext(ctx, query, args...)
+}
+
+func (m memoExt) clearIfWrite(query string) {
+	if !isMemoQuery(query) {
+		m.tx.ClearMemo()
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"sync"
+)
+
+// Metrics sink for transactions and queries.  Implement this to wire txwrap into
+// Prometheus, statsd, etc.  ObserveTx is called once per outer transaction (see
+// TxInfo for duration, commit/rollback, and retry counts) and ObserveQuery is
+// called after every DB call made through the TxWrap.
+type Metrics interface {
+	ObserveTx(ctx context.Context, info TxInfo)
+	ObserveQuery(ctx context.Context, info QueryInfo)
+}
+
+// Reports transaction and query metrics to 'm'.
+func WithMetrics(m Metrics) TxOption {
+	return CombineOptions(
+		WithTxHook(func(ctx context.Context) (context.Context, func(info TxInfo)) {
+			return ctx, func(info TxInfo) {
+				m.ObserveTx(ctx, info)
+			}
+		}),
+		WithQueryHook(m.ObserveQuery),
+	)
+}
+
+var globalLock = &sync.Mutex{}
+var defaultOpts []TxOption
+
+// Sets options that are applied to every transaction (before the options passed
+// to WithTx).  Typically called once at startup, e.g. to register WithMetrics.
+// Replaces any previously set default options.
+func SetDefaultOptions(opts ...TxOption) {
+	globalLock.Lock()
+	defer globalLock.Unlock()
+	defaultOpts = append([]TxOption(nil), opts...)
+}
+
+func getDefaultOptions() []TxOption {
+	globalLock.Lock()
+	defer globalLock.Unlock()
+	return defaultOpts
+}
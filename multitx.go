@@ -0,0 +1,236 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Returned from WithMultiTx and WithTx2PC when some (but not all) of the databases
+// committed.  The databases are no longer consistent with each other, the caller
+// must reconcile them (for WithTx2PC, resolve the InDoubt prepared transactions
+// with COMMIT PREPARED or ROLLBACK PREPARED).
+type PartialCommitError struct {
+	Committed []int    // indexes (into the dbs passed) of the databases that committed
+	Failed    int      // index of the database that failed to commit
+	InDoubt   []string // WithTx2PC only, transaction ids that are still prepared
+	Err       error
+}
+
+func (e *PartialCommitError) Error() string {
+	rtn := fmt.Sprintf("partial commit, db #%d failed to commit (committed %v)", e.Failed, e.Committed)
+	if len(e.InDoubt) > 0 {
+		rtn += fmt.Sprintf(", in-doubt prepared transactions %v", e.InDoubt)
+	}
+	return rtn + ": " + e.Err.Error()
+}
+
+func (e *PartialCommitError) Unwrap() error {
+	return e.Err
+}
+
+// Runs a transaction on each of 'dbs' and passes a TxWrap for each (in the same
+// order) to 'fn'.  If 'fn' returns an error or a DB call on any of the TxWraps fails,
+// all of the transactions are rolled back.  Otherwise they are committed in order.
+//
+// This is best-effort: if a commit fails after earlier databases committed, the
+// remaining transactions are rolled back and a *PartialCommitError is returned.  Use
+// WithTx2PC (Postgres) when all databases must commit or none.
+//
+// OnCommit callbacks run after all of the databases commit, OnRollback callbacks run
+// for each database that did not commit.  Query options apply to
+// each TxWrap (with WithDryRun or WithRollbackOnly all of the transactions are
+// rolled back), as do WithIsolation and WithReadOnly.  TxHooks and retries are not
+// supported.  Each TxWrap's Context() nests
+// into that TxWrap's transaction.
+func WithMultiTx(ctx context.Context, dbs []*sqlx.DB, fn func(txs []*TxWrap) error, opts ...TxOption) error {
+	return runMultiTx(ctx, dbs, fn, opts, (*multiTx).commitInOrder)
+}
+
+// Same as WithMultiTx, but uses two-phase commit (PREPARE TRANSACTION / COMMIT PREPARED)
+// so either all of the databases commit or none do.  Requires Postgres with
+// max_prepared_transactions > 0.
+//
+// If a database fails in the second phase (COMMIT PREPARED) a *PartialCommitError
+// is returned with the ids of the prepared transactions that are still in doubt.
+func WithTx2PC(ctx context.Context, dbs []*sqlx.DB, fn func(txs []*TxWrap) error, opts ...TxOption) error {
+	return runMultiTx(ctx, dbs, fn, opts, (*multiTx).commit2PC)
+}
+
+// state of the transactions in WithMultiTx/WithTx2PC
+type multiTx struct {
+	ctx       context.Context
+	dbs       []*sqlx.DB
+	txs       []*TxWrap
+	finished  []bool   // Commit or Rollback was called on the sql transaction
+	committed []bool   // the database committed (including COMMIT PREPARED)
+	closeFns  []func() // from setupTxExt
+}
+
+func (m *multiTx) committedIdxs() []int {
+	var rtn []int
+	for idx, committed := range m.committed {
+		if committed {
+			rtn = append(rtn, idx)
+		}
+	}
+	return rtn
+}
+
+// rolls back unfinished transactions and runs the rollback hooks for all
+// databases that did not commit
+func (m *multiTx) rollback(rbErr error) error {
+	var errs []error
+	for idx, txWrap := range m.txs {
+		if !m.finished[idx] {
+			m.finished[idx] = true
+			errs = append(errs, txWrap.rollback())
+		}
+		if !m.committed[idx] {
+			txWrap.runRollbackHooks(rbErr)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func runMultiTx(ctx context.Context, dbs []*sqlx.DB, fn func(txs []*TxWrap) error, opts []TxOption, commitFn func(m *multiTx) error) (rtnErr error) {
+	if IsTxWrapContext(ctx) {
+		return fmt.Errorf("multi-database transactions cannot be nested inside of a TxWrap transaction")
+	}
+	for idx, db := range dbs {
+		if db == nil {
+			return fmt.Errorf("invalid nil DB #%d passed to WithMultiTx", idx)
+		}
+	}
+	txOpts := makeTxOpts(opts)
+	m := &multiTx{ctx: ctx, dbs: dbs}
+	defer func() {
+		for idx, txWrap := range m.txs {
+			txWrap.cancelStmtTimeouts()
+			m.closeFns[idx]()
+		}
+	}()
+	success := false
+	defer func() {
+		if success {
+			return
+		}
+		if p := recover(); p != nil {
+			m.rollback(fmt.Errorf("panic in transaction: %v", p))
+			panic(p)
+		}
+		rbErr := m.rollback(rtnErr)
+		if rbErr != nil {
+			rtnErr = errors.Join(rtnErr, rbErr)
+		}
+	}()
+	sqlOpts := txOpts.makeSqlOpts(nil)
+	for _, db := range dbs {
+		tx, err := db.BeginTxx(ctx, sqlOpts)
+		if err != nil {
+			return err
+		}
+		txWrap := &TxWrap{Txx: tx, ctx: ctx, db: db, opts: txOpts, sqlOpts: sqlOpts}
+		m.closeFns = append(m.closeFns, txWrap.setupTxExt())
+		m.txs = append(m.txs, txWrap)
+		m.finished = append(m.finished, false)
+		m.committed = append(m.committed, false)
+	}
+	fnErr := fn(m.txs)
+	for _, txWrap := range m.txs {
+		txWrap.joinCollectedErrs()
+		if txWrap.Err != nil {
+			return txWrap.Err
+		}
+	}
+	if fnErr != nil {
+		return fnErr
+	}
+	if forcedErr := txOpts.forcedRollbackErr(); forcedErr != nil {
+		// WithDryRun or WithRollbackOnly, roll back all of the transactions
+		success = true
+		return m.rollback(forcedErr)
+	}
+	err := commitFn(m)
+	if err != nil {
+		return err
+	}
+	success = true
+	var hookErrs []error
+	for _, txWrap := range m.txs {
+		hookErrs = append(hookErrs, txWrap.runCommitHooks())
+	}
+	return errors.Join(hookErrs...)
+}
+
+// commits in order, stops at the first failure
+func (m *multiTx) commitInOrder() error {
+	for idx, txWrap := range m.txs {
+		m.finished[idx] = true
+		err := txWrap.Txx.Commit()
+		if err != nil {
+			if idx == 0 {
+				return err
+			}
+			return &PartialCommitError{Committed: m.committedIdxs(), Failed: idx, Err: err}
+		}
+		m.committed[idx] = true
+	}
+	return nil
+}
+
+func (m *multiTx) commit2PC() error {
+	gidBase := fmt.Sprintf("txwrap_%d_%08x", time.Now().UnixNano(), rand.Uint32())
+	gids := make([]string, len(m.txs))
+	prepared := make([]bool, len(m.txs))
+	// phase 2 (and cleanup of prepared transactions) must not be interrupted
+	bgCtx := context.WithoutCancel(m.ctx)
+	rollbackPrepared := func() error {
+		var errs []error
+		for idx := range m.txs {
+			if !prepared[idx] {
+				continue
+			}
+			_, err := m.dbs[idx].ExecContext(bgCtx, "ROLLBACK PREPARED '"+gids[idx]+"'")
+			if err != nil {
+				errs = append(errs, fmt.Errorf("rollback prepared %q failed: %w", gids[idx], err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+	// phase 1.  after PREPARE TRANSACTION the session is no longer in a transaction,
+	// Commit just returns the connection to the pool.
+	for idx, txWrap := range m.txs {
+		gids[idx] = fmt.Sprintf("%s_%d", gidBase, idx)
+		_, err := txWrap.Txx.ExecContext(m.ctx, "PREPARE TRANSACTION '"+gids[idx]+"'")
+		if err == nil {
+			prepared[idx] = true
+			m.finished[idx] = true
+			err = txWrap.Txx.Commit()
+		}
+		if err != nil {
+			err = fmt.Errorf("prepare transaction on db #%d failed: %w", idx, err)
+			return errors.Join(err, rollbackPrepared())
+		}
+	}
+	// phase 2
+	for idx := range m.txs {
+		_, err := m.dbs[idx].ExecContext(bgCtx, "COMMIT PREPARED '"+gids[idx]+"'")
+		if err != nil {
+			if idx == 0 {
+				err = fmt.Errorf("commit prepared on db #%d failed: %w", idx, err)
+				return errors.Join(err, rollbackPrepared())
+			}
+			return &PartialCommitError{Committed: m.committedIdxs(), Failed: idx, InDoubt: gids[idx:], Err: err}
+		}
+		m.committed[idx] = true
+	}
+	return nil
+}
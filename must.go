@@ -0,0 +1,150 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Panicking variants for CLI tools, tests, and initialization code where every error
+// is fatal.  The Must* TxWrap methods call the regular method and panic with tx.Err
+// (the same error WithTx would return, including the query when WithQueryErrors is
+// set) if it is set afterwards.  A panic inside of WithTx rolls back the transaction
+// before it propagates.
+
+// Same as WithTx, but panics if WithTx returns an error
+func MustWithTx(ctx context.Context, db *sqlx.DB, fn func(tx *TxWrap) error, opts ...TxOption) {
+	err := WithTx(ctx, db, fn, opts...)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Same as WithTxRtn, but panics if WithTxRtn returns an error
+func MustWithTxRtn[RT any](ctx context.Context, db *sqlx.DB, fn func(tx *TxWrap) (RT, error), opts ...TxOption) RT {
+	rtn, err := WithTxRtn(ctx, db, fn, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return rtn
+}
+
+func (tx *TxWrap) mustCheck() {
+	if tx.Err != nil {
+		panic(tx.Err)
+	}
+}
+
+func (tx *TxWrap) MustExec(query string, args ...interface{}) sql.Result {
+	rtn := tx.Exec(query, args...)
+	tx.mustCheck()
+	return rtn
+}
+
+func (tx *TxWrap) MustNamedExec(query string, arg interface{}) sql.Result {
+	rtn := tx.NamedExec(query, arg)
+	tx.mustCheck()
+	return rtn
+}
+
+func (tx *TxWrap) MustGet(dest interface{}, query string, args ...interface{}) bool {
+	rtn := tx.Get(dest, query, args...)
+	tx.mustCheck()
+	return rtn
+}
+
+func (tx *TxWrap) MustSelect(dest interface{}, query string, args ...interface{}) {
+	tx.Select(dest, query, args...)
+	tx.mustCheck()
+}
+
+func (tx *TxWrap) MustExists(query string, args ...interface{}) bool {
+	rtn := tx.Exists(query, args...)
+	tx.mustCheck()
+	return rtn
+}
+
+func (tx *TxWrap) MustGetString(query string, args ...interface{}) string {
+	rtn := tx.GetString(query, args...)
+	tx.mustCheck()
+	return rtn
+}
+
+func (tx *TxWrap) MustGetInt(query string, args ...interface{}) int {
+	rtn := tx.GetInt(query, args...)
+	tx.mustCheck()
+	return rtn
+}
+
+func (tx *TxWrap) MustGetInt64(query string, args ...interface{}) int64 {
+	rtn := tx.GetInt64(query, args...)
+	tx.mustCheck()
+	return rtn
+}
+
+func (tx *TxWrap) MustGetFloat64(query string, args ...interface{}) float64 {
+	rtn := tx.GetFloat64(query, args...)
+	tx.mustCheck()
+	return rtn
+}
+
+func (tx *TxWrap) MustGetBool(query string, args ...interface{}) bool {
+	rtn := tx.GetBool(query, args...)
+	tx.mustCheck()
+	return rtn
+}
+
+func (tx *TxWrap) MustGetTime(query string, args ...interface{}) time.Time {
+	rtn := tx.GetTime(query, args...)
+	tx.mustCheck()
+	return rtn
+}
+
+func (dbw *DBWrap) MustExec(query string, args ...interface{}) sql.Result {
+	return dbw.w.MustExec(query, args...)
+}
+
+func (dbw *DBWrap) MustNamedExec(query string, arg interface{}) sql.Result {
+	return dbw.w.MustNamedExec(query, arg)
+}
+
+func (dbw *DBWrap) MustGet(dest interface{}, query string, args ...interface{}) bool {
+	return dbw.w.MustGet(dest, query, args...)
+}
+
+func (dbw *DBWrap) MustSelect(dest interface{}, query string, args ...interface{}) {
+	dbw.w.MustSelect(dest, query, args...)
+}
+
+func (dbw *DBWrap) MustExists(query string, args ...interface{}) bool {
+	return dbw.w.MustExists(query, args...)
+}
+
+func (dbw *DBWrap) MustGetString(query string, args ...interface{}) string {
+	return dbw.w.MustGetString(query, args...)
+}
+
+func (dbw *DBWrap) MustGetInt(query string, args ...interface{}) int {
+	return dbw.w.MustGetInt(query, args...)
+}
+
+func (dbw *DBWrap) MustGetInt64(query string, args ...interface{}) int64 {
+	return dbw.w.MustGetInt64(query, args...)
+}
+
+func (dbw *DBWrap) MustGetFloat64(query string, args ...interface{}) float64 {
+	return dbw.w.MustGetFloat64(query, args...)
+}
+
+func (dbw *DBWrap) MustGetBool(query string, args ...interface{}) bool {
+	return dbw.w.MustGetBool(query, args...)
+}
+
+func (dbw *DBWrap) MustGetTime(query string, args ...interface{}) time.Time {
+	return dbw.w.MustGetTime(query, args...)
+}
@@ -0,0 +1,51 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Postgres limits (channel names are identifiers, payloads must be shorter than 8000 bytes)
+const (
+	maxNotifyChannelLen = 63
+	maxNotifyPayloadLen = 7999
+)
+
+// Sends a Postgres notification (pg_notify) from inside the transaction.  Listeners
+// only receive it if the transaction commits, so it is safe to notify before the
+// data is committed.  A string or []byte payload is sent as is, any other payload is
+// JSON encoded (nil sends an empty payload).  The channel must be a non-empty name
+// no longer than 63 bytes, and the payload must be less than 8000 bytes (Postgres
+// limits), otherwise an error is set on tx.  Only supported on Postgres.
+func (tx *TxWrap) Notify(channel string, payload interface{}) {
+	if !tx.checkPostgresDriver("Notify") {
+		return
+	}
+	if channel == "" || len(channel) > maxNotifyChannelLen {
+		tx.SetErr(fmt.Errorf("txwrap Notify, invalid channel name %q (must be 1-%d bytes)", channel, maxNotifyChannelLen))
+		return
+	}
+	var payloadStr string
+	switch p := payload.(type) {
+	case nil:
+	case string:
+		payloadStr = p
+	case []byte:
+		payloadStr = string(p)
+	default:
+		barr, err := json.Marshal(payload)
+		if err != nil {
+			tx.SetErr(fmt.Errorf("txwrap Notify, cannot encode payload for channel %q: %w", channel, err))
+			return
+		}
+		payloadStr = string(barr)
+	}
+	if len(payloadStr) > maxNotifyPayloadLen {
+		tx.SetErr(fmt.Errorf("txwrap Notify, payload for channel %q is too large (%d bytes, max %d)", channel, len(payloadStr), maxNotifyPayloadLen))
+		return
+	}
+	tx.Exec(`SELECT pg_notify($1, $2)`, channel, payloadStr)
+}
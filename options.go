@@ -0,0 +1,226 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"log/slog"
+	"time"
+)
+
+// Option for WithTx (and its variants).  Options are only applied when WithTx
+// begins a new transaction.  Nested calls that reuse an outer TxWrap ignore their
+// options, the outer transaction's options remain in effect.
+type TxOption func(opts *txOpts)
+
+type txOpts struct {
+	txHooks    []TxHook
+	queryHooks []QueryHook
+	redact     func(args []interface{}) []interface{}
+	errWrap    func(err error) error
+	errorHooks []func(ctx context.Context, op string, query string, err error) error
+	retry      *RetryPolicy
+	isolation  sql.IsolationLevel
+	readOnly   bool
+	statsFns   []func(ctx context.Context, stats TxStats)
+	auditFns   []func(ctx context.Context, trail []QueryInfo)
+	auditTable string
+	auditTrail bool
+	slogger    *slog.Logger
+	clock      Clock
+
+	selectExists  bool
+	strictNoRows  bool
+	recoverPanics bool
+	collectErrors bool
+	rollbackOnly  bool
+
+	serializedWrites bool
+
+	independentNestedTx bool
+
+	queryErrors    bool
+	queryErrorArgs bool
+
+	stmtCache   bool
+	faults      *FaultConfig
+	memoize     bool
+	autoRebind  bool
+	stmtTimeout time.Duration
+	dryRun      func(ctx context.Context, query string, args []interface{})
+
+	explain        func(ctx context.Context, info ExplainInfo)
+	explainAnalyze bool
+
+	rewriteFns    []func(ctx context.Context, query string, args []interface{}) (string, []interface{})
+	sqlCommenter  bool
+	commentTagsFn func(ctx context.Context) map[string]string
+}
+
+// Adds a QueryHook to the transaction.  Multiple hooks are called in order.
+func WithQueryHook(hook QueryHook) TxOption {
+	return func(opts *txOpts) {
+		opts.queryHooks = append(opts.queryHooks, hook)
+	}
+}
+
+// Adds a TxHook to the transaction.  Multiple hooks are called in order (and their
+// end functions are called in reverse order).
+func WithTxHook(hook TxHook) TxOption {
+	return func(opts *txOpts) {
+		opts.txHooks = append(opts.txHooks, hook)
+	}
+}
+
+// Combines multiple options into a single TxOption.  Useful for packages that
+// need to install more than one hook.
+func CombineOptions(opts ...TxOption) TxOption {
+	return func(txOpts *txOpts) {
+		for _, opt := range opts {
+			if opt != nil {
+				opt(txOpts)
+			}
+		}
+	}
+}
+
+// Sets a function to sanitize query arguments before they are passed to any
+// QueryHook (e.g. to strip emails, tokens, or large blobs).  'fn' is passed
+// a copy of the args and never affects the values sent to the database.  Use
+// Redact to mark individual args instead.
+func WithRedact(fn func(args []interface{}) []interface{}) TxOption {
+	return func(opts *txOpts) {
+		opts.redact = fn
+	}
+}
+
+// Sets a function to annotate the final (non-nil) error returned from WithTx,
+// e.g. to attach the logical name of the transaction.  For nested calls only the
+// outermost WithTx applies its ErrWrap function.
+func WithErrWrap(fn func(err error) error) TxOption {
+	return func(opts *txOpts) {
+		opts.errWrap = fn
+	}
+}
+
+// Adds a hook that is called when a DB call fails (before the error is set on
+// tx.Err).  The error returned from 'fn' replaces the DB error, e.g. to map driver
+// errors to domain errors (return 'err' or nil to keep it).  'op' is the TxWrap
+// method (as in QueryInfo).  Multiple hooks are called in order, each receiving the
+// error returned by the previous hook.  Use SetDefaultOptions to install a hook for
+// all transactions.
+func WithOnError(fn func(ctx context.Context, op string, query string, err error) error) TxOption {
+	return func(opts *txOpts) {
+		opts.errorHooks = append(opts.errorHooks, fn)
+	}
+}
+
+// Sets the isolation level of the transaction (same as WithTxOptions).  If
+// WithTxOptions is called with an explicit (non-default) isolation level, that level
+// is used instead.
+func WithIsolation(level sql.IsolationLevel) TxOption {
+	return func(opts *txOpts) {
+		opts.isolation = level
+	}
+}
+
+// Starts the transaction as read-only (same as WithTxReadOnly).  Exec calls are
+// rejected with ErrReadOnlyTx.
+func WithReadOnly() TxOption {
+	return func(opts *txOpts) {
+		opts.readOnly = true
+	}
+}
+
+// When a nested WithTx call passes a different DB than the outer transaction, begin
+// an independent transaction on that DB (committed or rolled back when the nested
+// call returns, regardless of the outer transaction) instead of returning
+// ErrNestedTxDB.  Unlike other options, this is read from the nested call's options
+// (or SetDefaultOptions).  The new transaction uses the nested call's options.
+func WithIndependentNestedTx() TxOption {
+	return func(opts *txOpts) {
+		opts.independentNestedTx = true
+	}
+}
+
+// Retries the transaction up to 'numRetries' times on retryable errors, using the
+// default RetryPolicy (see WithRetry).
+func WithRetries(numRetries int) TxOption {
+	return WithRetry(RetryPolicy{MaxAttempts: numRetries + 1})
+}
+
+// Logs every query (and its error) to 'logger' using QueryInfo.String().  Use
+// WithQueryLogger to only log slow or failed queries.
+func WithLogger(logger *log.Logger) TxOption {
+	return WithQueryLogger(0, func(ctx context.Context, info QueryInfo) {
+		logger.Printf("txwrap %s", info.String())
+	})
+}
+
+// Makes Exists and NamedExists wrap their query as SELECT EXISTS(<query>) so the
+// database can short-circuit instead of materializing a row.  Only use this when
+// the driver and all Exists queries support being wrapped in a subquery.
+func WithSelectExists() TxOption {
+	return func(opts *txOpts) {
+		opts.selectExists = true
+	}
+}
+
+// Makes Get (and the getters built on it, e.g. GetString, GetInt64, GetScalar) and
+// GetMap treat sql.ErrNoRows as an error, like GetRequired.  Exists is not affected.
+func WithStrictNoRows() TxOption {
+	return func(opts *txOpts) {
+		opts.strictNoRows = true
+	}
+}
+
+// Wraps every error from a DB call in a *QueryError that includes the failing
+// method and query (and, if 'includeArgs' is true, the query args, which are
+// redacted if WithRedact is set and truncated in the error message).
+func WithQueryErrors(includeArgs bool) TxOption {
+	return func(opts *txOpts) {
+		opts.queryErrors = true
+		opts.queryErrorArgs = includeArgs
+	}
+}
+
+func makeTxOpts(opts []TxOption) *txOpts {
+	rtn := &txOpts{}
+	for _, opt := range getDefaultOptions() {
+		if opt != nil {
+			opt(rtn)
+		}
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(rtn)
+		}
+	}
+	return rtn
+}
+
+// merges the WithIsolation/WithReadOnly options with the explicit sql.TxOptions
+func (opts *txOpts) makeSqlOpts(sqlOpts *sql.TxOptions) *sql.TxOptions {
+	if opts.isolation == sql.LevelDefault && !opts.readOnly {
+		return sqlOpts
+	}
+	var rtn sql.TxOptions
+	if sqlOpts != nil {
+		rtn = *sqlOpts
+	}
+	if rtn.Isolation == sql.LevelDefault {
+		rtn.Isolation = opts.isolation
+	}
+	rtn.ReadOnly = rtn.ReadOnly || opts.readOnly
+	return &rtn
+}
+
+func (opts *txOpts) wrapErr(err error) error {
+	if err == nil || opts.errWrap == nil {
+		return err
+	}
+	return opts.errWrap(err)
+}
@@ -0,0 +1,34 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"fmt"
+)
+
+// Returned from WithTx (with the RecoverPanics option) when 'fn' panics
+type PanicError struct {
+	Value interface{} // the value passed to panic
+	Stack []byte      // stack trace of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in transaction: %v", e.Value)
+}
+
+// Returns the panic value if it is an error
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// If 'fn' panics, the transaction is rolled back and WithTx returns a *PanicError
+// instead of re-raising the panic.  Panics are not retried.
+func RecoverPanics() TxOption {
+	return func(opts *txOpts) {
+		opts.recoverPanics = true
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"runtime/debug"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var pgSnapshotIdRe = regexp.MustCompile(`^[0-9A-Fa-f-]+$`)
+
+// Runs independent reads concurrently, each 'fn' in its own read-only transaction
+// on a separate pooled connection (from the DB the transaction was started on).
+// Waits for all of them, the errors (including panics, as *PanicError) are joined
+// and set on tx.Err.  Returns false if there is an error.
+//
+//	var user User
+//	var orders []Order
+//	tx.ParallelReads(func(q txwrap.Querier) error {
+//	    q.Get(&user, `SELECT * FROM users WHERE id = $1`, userId)
+//	    return nil
+//	}, func(q txwrap.Querier) error {
+//	    q.Select(&orders, `SELECT * FROM orders WHERE user_id = $1`, userId)
+//	    return nil
+//	})
+//
+// The reads do *not* see uncommitted writes made by this transaction.  On Postgres
+// they run on this transaction's snapshot (pg_export_snapshot, the reads are
+// REPEATABLE READ), so they see the same committed data as this transaction.  On
+// other databases each read sees the data committed when it starts.  Each 'fn'
+// must only use the Querier it is passed (Exec is rejected with ErrReadOnlyTx).  The
+// query options of this transaction apply, QueryHooks may be called concurrently.
+//
+// At most MaxOpenConnections-1 reads run at once (this transaction holds one
+// connection).  If the reads cannot run on other connections (a pinned connection
+// from WithTxConn, NewFromTx, or a pool limited to one connection) the functions
+// run one at a time inside of this transaction instead.
+func (tx *TxWrap) ParallelReads(fns ...func(q Querier) error) bool {
+	if tx.checkErr() {
+		return false
+	}
+	beginner, maxReads := tx.parallelBeginner()
+	if beginner == nil {
+		for _, fn := range fns {
+			tx.SetErr(fn(tx))
+			if tx.checkErr() {
+				return false
+			}
+		}
+		return true
+	}
+	sqlOpts := &sql.TxOptions{ReadOnly: true}
+	snapshotId := tx.exportSnapshot()
+	if tx.Err != nil {
+		return false
+	}
+	if snapshotId != "" {
+		sqlOpts.Isolation = sql.LevelRepeatableRead
+	}
+	// the reads are separate transactions, not nested in this one
+	ctx := context.WithValue(tx.ctx, txWrapKey{}, nil)
+	if maxReads <= 0 || maxReads > len(fns) {
+		maxReads = len(fns)
+	}
+	sem := make(chan struct{}, maxReads)
+	errs := make([]error, len(fns))
+	var wg sync.WaitGroup
+	for idx, fn := range fns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			err := tx.runParallelRead(ctx, beginner, sqlOpts, snapshotId, fn)
+			if err != nil {
+				errs[idx] = fmt.Errorf("ParallelReads #%d: %w", idx, err)
+			}
+		}()
+	}
+	wg.Wait()
+	tx.SetErr(errors.Join(errs...))
+	return tx.Err == nil
+}
+
+// returns nil if reads cannot run on other connections.  the second return value
+// is the max number of concurrent reads (0 for no limit).
+func (tx *TxWrap) parallelBeginner() (txBeginner, int) {
+	var pool *sqlx.DB
+	switch b := tx.db.(type) {
+	case *sqlx.DB:
+		pool = b
+	case replicaBeginner:
+		pool = b.replica
+	default:
+		return nil, 0
+	}
+	maxOpen := pool.Stats().MaxOpenConnections
+	if maxOpen == 1 {
+		return nil, 0
+	}
+	if maxOpen > 0 && tx.db == pool {
+		return tx.db, maxOpen - 1
+	}
+	return tx.db, maxOpen
+}
+
+// returns "" if the reads should not use this transaction's snapshot (not Postgres,
+// or started on a replica)
+func (tx *TxWrap) exportSnapshot() string {
+	if _, ok := tx.db.(*sqlx.DB); !ok || tx.Txx == nil || sqlx.BindType(tx.Txx.DriverName()) != sqlx.DOLLAR {
+		return ""
+	}
+	var snapshotId string
+	if !tx.QueryRowx(`SELECT pg_export_snapshot()`).Scan(&snapshotId) {
+		return ""
+	}
+	if !pgSnapshotIdRe.MatchString(snapshotId) {
+		tx.SetErr(fmt.Errorf("txwrap ParallelReads, invalid snapshot id %q", snapshotId))
+		return ""
+	}
+	return snapshotId
+}
+
+func (tx *TxWrap) runParallelRead(ctx context.Context, beginner txBeginner, sqlOpts *sql.TxOptions, snapshotId string, fn func(q Querier) error) (rtnErr error) {
+	defer func() {
+		if p := recover(); p != nil {
+			rtnErr = &PanicError{Value: p, Stack: debug.Stack()}
+		}
+	}()
+	_, err := runTx(ctx, beginner, sqlOpts, func(readTx *TxWrap) error {
+		if snapshotId != "" {
+			query := "SET TRANSACTION SNAPSHOT '" + snapshotId + "'"
+			if _, err := readTx.Txx.ExecContext(readTx.ctx, query); err != nil {
+				readTx.setQueryErr("ParallelReads", query, nil, err)
+				return readTx.Err
+			}
+		}
+		return fn(readTx)
+	}, tx.opts, nil)
+	if isForcedRollbackErr(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,45 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Set on tx.Err when Exec (or anything built on it: NamedExec, ExecBatch, BulkInsert,
+// InsertStruct, etc.) is called in a read-only transaction (sql.TxOptions.ReadOnly,
+// e.g. WithTxReadOnly, WithReadTx, or the WithReadOnly option).  The statement is
+// rejected by TxWrap before it is sent to the database, so this works with every
+// driver.  Queries that return rows (Get, Select, etc.) are not checked, so
+// INSERT ... RETURNING run with Get is still rejected only by the database.
+var ErrReadOnlyTx = errors.New("exec not allowed in read-only transaction")
+
+// wraps the query handle for read-only transactions (called when a TxWrap is created)
+func (tx *TxWrap) setupReadOnly() {
+	if !tx.isReadOnly() {
+		return
+	}
+	tx.ext = readOnlyExt{ExtContext: tx.queryer()}
+}
+
+func (tx *TxWrap) isReadOnly() bool {
+	return tx.sqlOpts != nil && tx.sqlOpts.ReadOnly
+}
+
+// sqlx.ExtContext that rejects Exec statements
+type readOnlyExt struct {
+	sqlx.ExtContext
+}
+
+func (r readOnlyExt) unwrapExt(ctx context.Context, query string) (sqlx.ExtContext, string) {
+	return r.ExtContext, query
+}
+
+func (r readOnlyExt) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return nil, ErrReadOnlyTx
+}
@@ -0,0 +1,74 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Rebinds all queries from ? placeholders to the driver's bindvar type (see
+// sqlx.Rebind) before they run, so queries written with ? work on Postgres ($1)
+// as well as MySQL and SQLite.  Queries that already use the driver's bindvars are
+// unchanged.  Note that every ? is replaced, including a ? inside of a string literal
+// or the Postgres jsonb ? operator.
+func WithAutoRebind() TxOption {
+	return func(opts *txOpts) {
+		opts.autoRebind = true
+	}
+}
+
+// Rebinds 'query' from ? placeholders to the driver's bindvar type (see sqlx.Rebind)
+func (tx *TxWrap) Rebind(query string) string {
+	return tx.queryer().Rebind(query)
+}
+
+// wraps the query handle with WithAutoRebind (called when a TxWrap is created)
+func (tx *TxWrap) setupAutoRebind() {
+	if tx.opts == nil || !tx.opts.autoRebind {
+		return
+	}
+	tx.ext = rebindExt{ext: tx.queryer()}
+}
+
+// sqlx.ExtContext that rebinds queries before running them
+type rebindExt struct {
+	ext sqlx.ExtContext
+}
+
+var _ sqlx.ExtContext = rebindExt{}
+
+func (r rebindExt) unwrapExt(ctx context.Context, query string) (sqlx.ExtContext, string) {
+	return r.ext, r.ext.Rebind(query)
+}
+
+func (r rebindExt) DriverName() string {
+	return r.ext.DriverName()
+}
+
+func (r rebindExt) Rebind(query string) string {
+	return r.ext.Rebind(query)
+}
+
+func (r rebindExt) BindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	return r.ext.BindNamed(query, arg)
+}
+
+func (r rebindExt) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.ext.ExecContext(ctx, r.ext.Rebind(query), args...)
+}
+
+func (r rebindExt) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.ext.QueryContext(ctx, r.ext.Rebind(query), args...)
+}
+
+func (r rebindExt) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	return r.ext.QueryxContext(ctx, r.ext.Rebind(query), args...)
+}
+
+func (r rebindExt) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	return r.ext.QueryRowxContext(ctx, r.ext.Rebind(query), args...)
+}
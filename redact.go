@@ -0,0 +1,77 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// Shown instead of a Redact value in QueryHooks, errors, and logs
+const RedactedPlaceholder = "[REDACTED]"
+
+// A query arg that is sent to the database as is, but is replaced with
+// RedactedPlaceholder everywhere TxWrap reports args (QueryHooks, WithSlog,
+// WithQueryLogger, QueryError.Args, WithDryRun, WithExplain, the audit trail).
+// Create with Redact.
+type RedactedArg struct {
+	Arg interface{}
+}
+
+// Marks a query arg as sensitive (password, token, PII), see RedactedArg:
+//
+//	tx.Exec(`UPDATE users SET password_hash = ? WHERE id = ?`, txwrap.Redact(hash), userId)
+func Redact(value interface{}) RedactedArg {
+	return RedactedArg{Arg: value}
+}
+
+// Returns the wrapped value converted to a driver.Value.  If the value cannot be
+// converted by database/sql (e.g. a slice for a driver with its own argument
+// conversion, like pgx) it is returned as is for the driver to convert.
+func (r RedactedArg) Value() (driver.Value, error) {
+	rtn, err := driver.DefaultParameterConverter.ConvertValue(r.Arg)
+	if err != nil {
+		return r.Arg, nil
+	}
+	return rtn, nil
+}
+
+func (r RedactedArg) String() string {
+	return RedactedPlaceholder
+}
+
+func (r RedactedArg) GoString() string {
+	return RedactedPlaceholder
+}
+
+func (r RedactedArg) MarshalJSON() ([]byte, error) {
+	return json.Marshal(RedactedPlaceholder)
+}
+
+// returns the args to report (to hooks, errors, etc.): Redact values are replaced
+// and the WithRedact function is applied.  'args' is never modified.
+func (opts *txOpts) redactArgs(args []interface{}) []interface{} {
+	hasRedacted := false
+	for _, arg := range args {
+		if _, ok := arg.(RedactedArg); ok {
+			hasRedacted = true
+			break
+		}
+	}
+	if !hasRedacted && opts.redact == nil {
+		return args
+	}
+	rtn := append([]interface{}(nil), args...)
+	if hasRedacted {
+		for idx, arg := range rtn {
+			if _, ok := arg.(RedactedArg); ok {
+				rtn[idx] = RedactedPlaceholder
+			}
+		}
+	}
+	if opts.redact != nil {
+		rtn = opts.redact(rtn)
+	}
+	return rtn
+}
@@ -0,0 +1,119 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	DefaultRetryMaxAttempts = 3
+	DefaultRetryBaseDelay   = 10 * time.Millisecond
+	DefaultRetryMaxDelay    = 1 * time.Second
+)
+
+// Controls how WithTxRetry (or the WithRetry option) re-runs a transaction.
+// Zero values are replaced with the defaults.
+type RetryPolicy struct {
+	MaxAttempts int           // total number of attempts (including the first)
+	BaseDelay   time.Duration // delay before the first retry, doubles (with jitter) on each retry
+	MaxDelay    time.Duration // maximum delay between attempts
+
+	// Returns true if the transaction should be retried after failing with 'err'.
+	// Defaults to IsRetryableError.  Set this to handle driver specific errors.
+	IsRetryable func(err error) bool
+
+	// Also retry when Commit fails (with any error, see ErrCommitFailed).  Only set
+	// this if the transaction is idempotent, the commit may have succeeded on the
+	// server even though an error was returned (e.g. a dropped connection).
+	RetryCommitFailed bool
+}
+
+// When the transaction fails with a retryable error (see RetryPolicy.IsRetryable)
+// the whole transaction (begin, fn, commit) is re-run.  'fn' must be safe to
+// run multiple times.  Retries are only performed by the outermost WithTx, nested
+// calls ignore this option.  Errors returned from OnCommit callbacks are never
+// retried (the transaction has already committed).
+func WithRetry(policy RetryPolicy) TxOption {
+	return func(opts *txOpts) {
+		opts.retry = &policy
+	}
+}
+
+// Same as WithTx, with the WithRetry(policy) option.
+func WithTxRetry(ctx context.Context, db *sqlx.DB, policy RetryPolicy, fn func(tx *TxWrap) error, opts ...TxOption) error {
+	return WithTx(ctx, db, fn, append([]TxOption{WithRetry(policy)}, opts...)...)
+}
+
+// Runs 'fn' in a SERIALIZABLE transaction that is retried on serialization failures
+// (SQLSTATE 40001) and deadlocks, with jittered exponential backoff, for up to
+// 'maxAttempts' attempts in total (DefaultRetryMaxAttempts if <= 0).  A serialization
+// failure reported by Commit is also retried (the transaction did not commit).  'fn'
+// must be safe to run multiple times.  Use WithRetry in 'opts' to override the
+// backoff settings.
+func WithSerializableTx(ctx context.Context, db *sqlx.DB, maxAttempts int, fn func(tx *TxWrap) error, opts ...TxOption) error {
+	policy := RetryPolicy{MaxAttempts: maxAttempts}
+	return WithTxSerializable(ctx, db, fn, append([]TxOption{WithRetry(policy)}, opts...)...)
+}
+
+// Default retry predicate.  Returns true for serialization failures, deadlocks, and
+// SQLite busy/locked errors (see IsSerializationFailure, IsDeadlock, and IsSQLiteBusy).
+func IsRetryableError(err error) bool {
+	return IsSerializationFailure(err) || IsDeadlock(err) || IsSQLiteBusy(err)
+}
+
+func (p *RetryPolicy) run(ctx context.Context, clock Clock, attemptFn func() error) error {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultRetryMaxAttempts
+	}
+	isRetryable := p.IsRetryable
+	if isRetryable == nil {
+		isRetryable = IsRetryableError
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = attemptFn()
+		retryable := isRetryable(err) || (p.RetryCommitFailed && errors.Is(err, ErrCommitFailed))
+		if err == nil || attempt >= maxAttempts || !retryable {
+			return err
+		}
+		timer := clock.NewTimer(p.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.Chan():
+		}
+	}
+}
+
+// jittered exponential backoff, returns a delay in [d/2, d]
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	baseDelay := p.BaseDelay
+	if baseDelay <= 0 {
+		baseDelay = DefaultRetryBaseDelay
+	}
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultRetryMaxDelay
+	}
+	d := baseDelay
+	for i := 1; i < attempt && d < maxDelay; i++ {
+		d *= 2
+	}
+	if d > maxDelay {
+		d = maxDelay
+	}
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+	return time.Duration(half + rand.Int64N(half+1))
+}
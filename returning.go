@@ -0,0 +1,89 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Runs a write with a RETURNING clause (INSERT/UPDATE/DELETE ... RETURNING, on
+// Postgres, SQLite 3.35+, or MariaDB) and scans the first returned row into 'dest'
+// (a pointer to a struct or a scalar, same as Get).  Returns the number of rows
+// returned, which for a RETURNING clause is the number of rows affected.  Unlike
+// Get, no returned rows is not an error (e.g. an UPDATE that matched nothing), and
+// 'dest' is not changed.  Reported to QueryHooks (and TxStats) as a write, with
+// RowsAffected set.
+//
+//	var user User
+//	tx.ExecReturning(&user, `INSERT INTO users (name) VALUES ($1) RETURNING id, created_at`, name)
+//
+// Follows the Exec rules for WithDryRun (reported, not executed, returns 0) and
+// read-only transactions (ErrReadOnlyTx).
+func (tx *TxWrap) ExecReturning(dest interface{}, query string, args ...interface{}) int64 {
+	destVal := reflect.ValueOf(dest)
+	if destVal.Kind() != reflect.Pointer || destVal.IsNil() {
+		tx.SetErr(fmt.Errorf("txwrap ExecReturning, dest must be a non-nil pointer (got %T)", dest))
+		return 0
+	}
+	rowsPtr := reflect.New(reflect.SliceOf(destVal.Type().Elem()))
+	numRows := tx.execReturning("ExecReturning", rowsPtr.Interface(), query, args)
+	if numRows > 0 {
+		destVal.Elem().Set(rowsPtr.Elem().Index(0))
+	}
+	return numRows
+}
+
+// Same as ExecReturning, but scans all of the returned rows into 'dest' (a pointer
+// to a slice, same as Select).
+func (tx *TxWrap) SelectReturning(dest interface{}, query string, args ...interface{}) int64 {
+	return tx.execReturning("SelectReturning", dest, query, args)
+}
+
+func (tx *TxWrap) execReturning(op string, dest interface{}, query string, args []interface{}) int64 {
+	if tx.checkErr() {
+		return 0
+	}
+	startTs := tx.now()
+	if tx.isReadOnly() {
+		tx.observeExec(op, query, args, startTs, nil, ErrReadOnlyTx)
+		tx.setQueryErr(op, query, args, ErrReadOnlyTx)
+		return 0
+	}
+	if tx.opts != nil && tx.opts.dryRun != nil {
+		result, _ := dryRunExt{opts: tx.opts}.ExecContext(tx.ctx, query, args...)
+		tx.observeExec(op, query, args, startTs, result, nil)
+		return 0
+	}
+	err := tx.prepareStmt(query)
+	if err == nil {
+		err = sqlx.SelectContext(tx.ctx, tx.queryer(), dest, query, args...)
+	}
+	var numRows int64
+	if err == nil {
+		numRows = int64(reflect.ValueOf(dest).Elem().Len())
+	}
+	tx.observeExec(op, query, args, startTs, returningResult{numRows: numRows}, err)
+	if err != nil {
+		tx.setQueryErr(op, query, args, err)
+		return 0
+	}
+	return numRows
+}
+
+// sql.Result for ExecReturning and SelectReturning
+type returningResult struct {
+	numRows int64
+}
+
+func (r returningResult) LastInsertId() (int64, error) {
+	return 0, errors.New("LastInsertId not available for RETURNING queries")
+}
+
+func (r returningResult) RowsAffected() (int64, error) {
+	return r.numRows, nil
+}
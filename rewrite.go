@@ -0,0 +1,88 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Rewrites every query (and its args) before it runs, e.g. to add optimizer hints
+// or a tenant predicate.  Applies to all TxWrap DB calls (Exec, NamedExec, Get,
+// Select, GetMap, SelectMaps, etc.).  Named queries are passed to 'fn' after the
+// named parameters are bound.  'fn' sees the query before WithAutoRebind and
+// WithSQLCommenter are applied.  Multiple rewrite functions run in the order they
+// were added.  QueryHooks (and error messages) report the original query.
+//
+// 'fn' should only depend on its inputs, it may be called more than once for a
+// query (with WithStmtCache it is also called with nil args to prepare the query).
+// Statements prepared with Preparex are not rewritten.
+func WithQueryRewrite(fn func(ctx context.Context, query string, args []interface{}) (string, []interface{})) TxOption {
+	return func(opts *txOpts) {
+		opts.rewriteFns = append(opts.rewriteFns, fn)
+	}
+}
+
+// wraps the query handle with WithQueryRewrite (called when a TxWrap is created)
+func (tx *TxWrap) setupQueryRewrite() {
+	if tx.opts == nil || len(tx.opts.rewriteFns) == 0 {
+		return
+	}
+	tx.ext = rewriteExt{ext: tx.queryer(), opts: tx.opts}
+}
+
+// sqlx.ExtContext that rewrites queries before running them
+type rewriteExt struct {
+	ext  sqlx.ExtContext
+	opts *txOpts
+}
+
+var _ sqlx.ExtContext = rewriteExt{}
+
+func (r rewriteExt) rewrite(ctx context.Context, query string, args []interface{}) (string, []interface{}) {
+	for _, fn := range r.opts.rewriteFns {
+		query, args = fn(ctx, query, args)
+	}
+	return query, args
+}
+
+// the rewritten query is prepared without args (the args are not known yet)
+func (r rewriteExt) unwrapExt(ctx context.Context, query string) (sqlx.ExtContext, string) {
+	query, _ = r.rewrite(ctx, query, nil)
+	return r.ext, query
+}
+
+func (r rewriteExt) DriverName() string {
+	return r.ext.DriverName()
+}
+
+func (r rewriteExt) Rebind(query string) string {
+	return r.ext.Rebind(query)
+}
+
+func (r rewriteExt) BindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	return r.ext.BindNamed(query, arg)
+}
+
+func (r rewriteExt) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query, args = r.rewrite(ctx, query, args)
+	return r.ext.ExecContext(ctx, query, args...)
+}
+
+func (r rewriteExt) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query, args = r.rewrite(ctx, query, args)
+	return r.ext.QueryContext(ctx, query, args...)
+}
+
+func (r rewriteExt) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	query, args = r.rewrite(ctx, query, args)
+	return r.ext.QueryxContext(ctx, query, args...)
+}
+
+func (r rewriteExt) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	query, args = r.rewrite(ctx, query, args)
+	return r.ext.QueryRowxContext(ctx, query, args...)
+}
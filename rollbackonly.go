@@ -0,0 +1,51 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Passed to OnRollback callbacks when a WithRollbackOnly transaction is rolled back
+var ErrRollbackOnly = errors.New("rollback-only transaction, rolled back")
+
+// Runs the transaction normally (all statements execute, including writes), but
+// always rolls it back at the end (also applies to WithMultiTx and WithTx2PC).
+// WithTx returns nil if there were no errors, OnCommit callbacks are not run, and
+// OnRollback callbacks are passed ErrRollbackOnly.  Intended for tests that run real queries against a shared
+// database without changing it.  Use SetDefaultOptions to enable it for every
+// transaction (e.g. in TestMain):
+//
+//	txwrap.SetDefaultOptions(txwrap.WithRollbackOnly())
+//
+// Reads made after the transaction (from other transactions) do not see its writes.
+func WithRollbackOnly() TxOption {
+	return func(opts *txOpts) {
+		opts.rollbackOnly = true
+	}
+}
+
+// Runs WithTx with WithRollbackOnly
+func WithTxRollbackOnly(ctx context.Context, db *sqlx.DB, fn func(tx *TxWrap) error, opts ...TxOption) error {
+	return WithTx(ctx, db, fn, append(opts, WithRollbackOnly())...)
+}
+
+// returns the error passed to OnRollback callbacks if the transaction must be rolled
+// back even though it succeeded (nil for a normal transaction)
+func (opts *txOpts) forcedRollbackErr() error {
+	if opts.dryRun != nil {
+		return ErrDryRun
+	}
+	if opts.rollbackOnly {
+		return ErrRollbackOnly
+	}
+	return nil
+}
+
+func isForcedRollbackErr(err error) bool {
+	return err == ErrDryRun || err == ErrRollbackOnly
+}
@@ -0,0 +1,110 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Row locking clause for GetLocked / SelectLocked.  Combine LockForUpdate or
+// LockForShare with (optionally) LockNoWait or LockSkipLocked:
+//
+//	tx.SelectLocked(&jobs, txwrap.LockForUpdate|txwrap.LockSkipLocked, query, args...)
+type RowLock int
+
+const (
+	LockForUpdate  RowLock = 1 << iota // FOR UPDATE
+	LockForShare                       // FOR SHARE
+	LockNoWait                         // NOWAIT, fail instead of waiting for a locked row
+	LockSkipLocked                     // SKIP LOCKED, skip rows that are locked
+)
+
+// Same as Get, but appends the row locking clause for 'lock' to the query.  The
+// clause is driver-aware: Postgres and MySQL (8.0+) use FOR UPDATE / FOR SHARE
+// [NOWAIT | SKIP LOCKED].  SQLite has no row locks (a write transaction locks the
+// whole database), so no clause is added.  For other drivers that do not support
+// the clause (e.g. SQL Server) tx.Err is set.
+func (tx *TxWrap) GetLocked(dest interface{}, lock RowLock, query string, args ...interface{}) bool {
+	lockedQuery, ok := tx.lockQuery(query, lock)
+	if !ok {
+		return false
+	}
+	return tx.Get(dest, lockedQuery, args...)
+}
+
+// Same as Select, but appends the row locking clause for 'lock' (see GetLocked)
+func (tx *TxWrap) SelectLocked(dest interface{}, lock RowLock, query string, args ...interface{}) {
+	lockedQuery, ok := tx.lockQuery(query, lock)
+	if !ok {
+		return
+	}
+	tx.Select(dest, lockedQuery, args...)
+}
+
+// Runs GetLocked with LockForUpdate
+func (tx *TxWrap) GetForUpdate(dest interface{}, query string, args ...interface{}) bool {
+	return tx.GetLocked(dest, LockForUpdate, query, args...)
+}
+
+// Runs SelectLocked with LockForUpdate
+func (tx *TxWrap) SelectForUpdate(dest interface{}, query string, args ...interface{}) {
+	tx.SelectLocked(dest, LockForUpdate, query, args...)
+}
+
+// Runs GetLocked with LockForShare
+func (tx *TxWrap) GetForShare(dest interface{}, query string, args ...interface{}) bool {
+	return tx.GetLocked(dest, LockForShare, query, args...)
+}
+
+// Runs SelectLocked with LockForShare
+func (tx *TxWrap) SelectForShare(dest interface{}, query string, args ...interface{}) {
+	tx.SelectLocked(dest, LockForShare, query, args...)
+}
+
+func (tx *TxWrap) lockQuery(query string, lock RowLock) (string, bool) {
+	if tx.checkErr() {
+		return "", false
+	}
+	clause, err := rowLockClause(tx.queryer().DriverName(), lock)
+	if err != nil {
+		tx.Err = err
+		return "", false
+	}
+	if clause == "" {
+		return query, true
+	}
+	query = strings.TrimRight(query, " \t\r\n;")
+	return query + " " + clause, true
+}
+
+func rowLockClause(driverName string, lock RowLock) (string, error) {
+	var clause string
+	switch lock & (LockForUpdate | LockForShare) {
+	case LockForUpdate:
+		clause = "FOR UPDATE"
+	case LockForShare:
+		clause = "FOR SHARE"
+	default:
+		return "", fmt.Errorf("invalid RowLock %d, must set one of LockForUpdate or LockForShare", lock)
+	}
+	switch lock & (LockNoWait | LockSkipLocked) {
+	case LockNoWait:
+		clause += " NOWAIT"
+	case LockSkipLocked:
+		clause += " SKIP LOCKED"
+	case 0:
+	default:
+		return "", fmt.Errorf("invalid RowLock %d, cannot set both LockNoWait and LockSkipLocked", lock)
+	}
+	if strings.HasPrefix(driverName, "sqlite") {
+		return "", nil
+	}
+	if sqlx.BindType(driverName) == sqlx.AT {
+		return "", fmt.Errorf("row locking clauses are not supported for driver %q", driverName)
+	}
+	return clause, nil
+}
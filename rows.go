@@ -0,0 +1,214 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Error-latching wrapper around *sqlx.Rows returned by TxWrap.Queryx.  Errors from
+// Next (rows.Err), Scan, StructScan, MapScan, and SliceScan are set on the TxWrap,
+// and once the TxWrap has an error Next returns false.  TxRows that are still open
+// when the transaction finishes are closed automatically (calling Close early is
+// still recommended to release the connection for other queries).
+//
+//	rows := tx.Queryx(`SELECT id, name FROM users`)
+//	defer rows.Close()
+//	for rows.Next() {
+//	    var u User
+//	    rows.StructScan(&u)
+//	}
+type TxRows struct {
+	rows  *sqlx.Rows // nil if the query failed
+	tx    *TxWrap
+	query string
+	args  []interface{}
+}
+
+// Runs a query and returns the (managed) rows.  Never returns nil, if there is an
+// error the returned TxRows are empty.
+func (tx *TxWrap) Queryx(query string, args ...interface{}) *TxRows {
+	rtn := &TxRows{tx: tx, query: query, args: args}
+	if tx.checkErr() {
+		return rtn
+	}
+	startTs := tx.now()
+	rows, err := tx.queryer().QueryxContext(tx.ctx, query, args...)
+	tx.observeQuery("Queryx", query, args, startTs, err)
+	if err != nil {
+		tx.setQueryErr("Queryx", query, args, err)
+		return rtn
+	}
+	rtn.rows = rows
+	tx.openRows = append(tx.openRows, rtn)
+	return rtn
+}
+
+func (r *TxRows) setErr(err error) bool {
+	if err == nil {
+		return true
+	}
+	r.tx.setQueryErr("Queryx", r.query, r.args, err)
+	r.Close()
+	return false
+}
+
+// Advances to the next row.  Returns false when there are no more rows or
+// there is an error (rows.Err() is set on the TxWrap).
+func (r *TxRows) Next() bool {
+	if r.rows == nil {
+		return false
+	}
+	if r.tx.checkErr() {
+		r.Close()
+		return false
+	}
+	if r.rows.Next() {
+		return true
+	}
+	r.setErr(r.rows.Err())
+	r.Close()
+	return false
+}
+
+// Returns false (and sets the TxWrap error) if the scan fails
+func (r *TxRows) Scan(dest ...interface{}) bool {
+	if r.rows == nil || r.tx.Err != nil {
+		return false
+	}
+	return r.setErr(r.rows.Scan(dest...))
+}
+
+// Returns false (and sets the TxWrap error) if the scan fails
+func (r *TxRows) StructScan(dest interface{}) bool {
+	if r.rows == nil || r.tx.Err != nil {
+		return false
+	}
+	return r.setErr(r.rows.StructScan(dest))
+}
+
+// Returns false (and sets the TxWrap error) if the scan fails
+func (r *TxRows) MapScan(dest map[string]interface{}) bool {
+	if r.rows == nil || r.tx.Err != nil {
+		return false
+	}
+	return r.setErr(r.rows.MapScan(dest))
+}
+
+// Returns nil (and sets the TxWrap error) if the scan fails
+func (r *TxRows) SliceScan() []interface{} {
+	if r.rows == nil || r.tx.Err != nil {
+		return nil
+	}
+	rtn, err := r.rows.SliceScan()
+	if !r.setErr(err) {
+		return nil
+	}
+	return rtn
+}
+
+// Returns the column names (nil if there is an error)
+func (r *TxRows) Columns() []string {
+	if r.rows == nil || r.tx.Err != nil {
+		return nil
+	}
+	cols, err := r.rows.Columns()
+	if !r.setErr(err) {
+		return nil
+	}
+	return cols
+}
+
+// Closes the rows.  Safe to call multiple times.
+func (r *TxRows) Close() {
+	if r.rows == nil {
+		return
+	}
+	r.rows.Close()
+	r.rows = nil
+	for idx, openRows := range r.tx.openRows {
+		if openRows == r {
+			r.tx.openRows = append(r.tx.openRows[:idx], r.tx.openRows[idx+1:]...)
+			break
+		}
+	}
+}
+
+// closes any TxRows left open by Queryx (called before commit/rollback)
+func (tx *TxWrap) closeOpenRows() {
+	for len(tx.openRows) > 0 {
+		tx.openRows[0].Close()
+	}
+}
+
+// Error-latching wrapper around *sqlx.Row returned by TxWrap.QueryRowx.  Scan,
+// StructScan, and MapScan return true if a row was scanned.  Errors are set on the
+// TxWrap.  sql.ErrNoRows is handled like Get: it is not an error unless the
+// WithStrictNoRows option is set.  Only one of the scan methods may be called.
+type TxRow struct {
+	row     *sqlx.Row // nil if there is an error
+	tx      *TxWrap
+	query   string
+	args    []interface{}
+	startTs time.Time
+}
+
+// Runs a query that returns (at most) one row, scan the row with the returned TxRow.
+// Never returns nil.
+func (tx *TxWrap) QueryRowx(query string, args ...interface{}) *TxRow {
+	rtn := &TxRow{tx: tx, query: query, args: args}
+	if tx.checkErr() {
+		return rtn
+	}
+	rtn.startTs = tx.now()
+	err := tx.prepareRowStmt(query)
+	if err != nil {
+		tx.observeQuery("QueryRowx", query, args, rtn.startTs, err)
+		tx.setQueryErr("QueryRowx", query, args, err)
+		return rtn
+	}
+	rtn.row = tx.queryer().QueryRowxContext(tx.ctx, query, args...)
+	return rtn
+}
+
+func (r *TxRow) scan(scanFn func(row *sqlx.Row) error) bool {
+	if r.row == nil || r.tx.checkErr() {
+		return false
+	}
+	err := scanFn(r.row)
+	r.row = nil
+	r.tx.observeQuery("QueryRowx", r.query, r.args, r.startTs, err)
+	if err == sql.ErrNoRows {
+		if r.tx.isStrictNoRows() {
+			r.tx.setQueryErr("QueryRowx", r.query, r.args, noRowsError(r.query))
+		}
+		return false
+	}
+	if err != nil {
+		r.tx.setQueryErr("QueryRowx", r.query, r.args, err)
+		return false
+	}
+	return true
+}
+
+func (r *TxRow) Scan(dest ...interface{}) bool {
+	return r.scan(func(row *sqlx.Row) error {
+		return row.Scan(dest...)
+	})
+}
+
+func (r *TxRow) StructScan(dest interface{}) bool {
+	return r.scan(func(row *sqlx.Row) error {
+		return row.StructScan(dest)
+	})
+}
+
+func (r *TxRow) MapScan(dest map[string]interface{}) bool {
+	return r.scan(func(row *sqlx.Row) error {
+		return row.MapScan(dest)
+	})
+}
@@ -0,0 +1,192 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var savepointNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// a savepoint created with Savepoint (or WithSavepoint)
+type savepointMark struct {
+	name           string
+	numHooks       int // len(tx.commitHooks) when the savepoint was created
+	numBeforeHooks int // len(tx.beforeHooks) when the savepoint was created
+}
+
+// Runs 'fn' inside of a SAVEPOINT.  If 'fn' returns an error (or a DB call inside
+// of 'fn' fails) the transaction is rolled back to the savepoint, tx.Err is cleared,
+// and the error is returned to the caller.  The outer transaction can continue and
+// will still be committed.  OnCommit and BeforeCommit callbacks registered inside
+// 'fn' are discarded when the savepoint is rolled back.
+//
+// If tx.Err is already set, returns tx.Err immediately without running 'fn'.  With
+// WithCollectErrors the error is also kept in tx.Errs() (so WithTx still returns it).
+func WithSavepoint(tx *TxWrap, fn func() error) error {
+	_, err := WithSavepointRtn(tx, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// Same as WithSavepoint, but returns a value from 'fn'.  On error the zero value
+// of RT is returned.
+func WithSavepointRtn[RT any](tx *TxWrap, fn func() (RT, error)) (RT, error) {
+	var rtn RT
+	if tx.checkErr() {
+		return rtn, tx.Err
+	}
+	tx.savepointNum++
+	spName := fmt.Sprintf("txwrap_sp_%d", tx.savepointNum)
+	if !tx.Savepoint(spName) {
+		return rtn, tx.Err
+	}
+	temp, fnErr := fn()
+	innerErr := tx.Err
+	if innerErr == nil {
+		innerErr = fnErr
+	}
+	if innerErr != nil {
+		tx.SetErr(innerErr)
+		if ok, _ := tx.rollbackTo(spName); !ok {
+			// rollback failed, tx.Err includes innerErr
+			return rtn, tx.Err
+		}
+		if !tx.ReleaseSavepoint(spName) {
+			return rtn, errors.Join(innerErr, tx.Err)
+		}
+		if tx.isCollectErrors() {
+			// the savepoint is rolled back, but the error is still reported from WithTx
+			tx.errs = append(tx.errs, innerErr)
+		}
+		return rtn, innerErr
+	}
+	if !tx.ReleaseSavepoint(spName) {
+		return rtn, tx.Err
+	}
+	return temp, nil
+}
+
+// Creates a savepoint (SAVEPOINT name).  Returns false if there is an error (set
+// on tx.Err).  'name' must be a valid identifier (letters, digits, and _).  Use
+// RollbackTo to undo the statements run after the savepoint (e.g. for an optional
+// step that is allowed to fail), and ReleaseSavepoint to keep them:
+//
+//	tx.Savepoint("optional_step")
+//	tx.Exec(`INSERT INTO audit ...`)
+//	if tx.Err != nil {
+//	    err := tx.RollbackTo("optional_step") // clears tx.Err
+//	    ...
+//	}
+//	tx.ReleaseSavepoint("optional_step")
+//
+// Prefer WithSavepoint, which handles the rollback and release automatically.
+func (tx *TxWrap) Savepoint(name string) bool {
+	if tx.checkErr() {
+		return false
+	}
+	if !tx.execSavepointStmt("Savepoint", "SAVEPOINT", name) {
+		return false
+	}
+	tx.savepoints = append(tx.savepoints, savepointMark{name: name, numHooks: len(tx.commitHooks), numBeforeHooks: len(tx.beforeHooks)})
+	return true
+}
+
+// Rolls back to the savepoint 'name' (ROLLBACK TO SAVEPOINT name), undoing the DB
+// calls made after it.  Unlike the other TxWrap methods, this runs even if tx.Err is
+// set: on success tx.Err is cleared (so the transaction can continue) and the error
+// that was cleared is returned (nil if there was none).  OnCommit and BeforeCommit
+// callbacks registered after the savepoint are discarded.  The savepoint still exists
+// afterwards (it can be rolled back to again).  If the rollback fails, tx.Err is set
+// and returned.
+func (tx *TxWrap) RollbackTo(name string) error {
+	ok, prevErr := tx.rollbackTo(name)
+	if !ok {
+		return tx.Err
+	}
+	return prevErr
+}
+
+// returns true and the error that was cleared if the rollback succeeded, false if it
+// failed (tx.Err is set).  errors are not compared to detect failure since the error
+// types returned from user code may not be comparable.
+func (tx *TxWrap) rollbackTo(name string) (bool, error) {
+	if tx.ctx != nil && tx.ctx.Err() != nil {
+		tx.SetErr(tx.ctx.Err())
+		return false, nil
+	}
+	idx := tx.findSavepoint(name)
+	if idx == -1 {
+		tx.SetErr(fmt.Errorf("txwrap RollbackTo, no savepoint named %q", name))
+		return false, nil
+	}
+	prevErr := tx.Err
+	tx.Err = nil
+	if !tx.execSavepointStmt("RollbackTo", "ROLLBACK TO SAVEPOINT", name) {
+		if prevErr != nil {
+			tx.Err = errors.Join(prevErr, tx.Err)
+		}
+		return false, nil
+	}
+	tx.ClearMemo()
+	mark := tx.savepoints[idx]
+	tx.commitHooks = tx.commitHooks[:mark.numHooks]
+	tx.beforeHooks = tx.beforeHooks[:mark.numBeforeHooks]
+	tx.savepoints = tx.savepoints[:idx+1]
+	return true, prevErr
+}
+
+// Releases the savepoint 'name' (RELEASE SAVEPOINT name), keeping the DB calls made
+// after it (and releasing any savepoints created after it).  Returns false if there
+// is an error (set on tx.Err).
+func (tx *TxWrap) ReleaseSavepoint(name string) bool {
+	if tx.checkErr() {
+		return false
+	}
+	idx := tx.findSavepoint(name)
+	if idx == -1 {
+		tx.SetErr(fmt.Errorf("txwrap ReleaseSavepoint, no savepoint named %q", name))
+		return false
+	}
+	if !tx.execSavepointStmt("ReleaseSavepoint", "RELEASE SAVEPOINT", name) {
+		return false
+	}
+	tx.savepoints = tx.savepoints[:idx]
+	return true
+}
+
+// returns the index of the most recent savepoint named 'name' (-1 if not found)
+func (tx *TxWrap) findSavepoint(name string) int {
+	for idx := len(tx.savepoints) - 1; idx >= 0; idx-- {
+		if tx.savepoints[idx].name == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+// savepoint statements always run directly on the transaction (not through the
+// statement cache or WithDryRun)
+func (tx *TxWrap) execSavepointStmt(op string, stmt string, name string) bool {
+	if tx.Txx == nil {
+		tx.SetErr(fmt.Errorf("txwrap %s, savepoints require a transaction", op))
+		return false
+	}
+	if !savepointNameRe.MatchString(name) {
+		tx.SetErr(fmt.Errorf("txwrap %s, invalid savepoint name %q", op, name))
+		return false
+	}
+	query := stmt + " " + name
+	startTs := tx.now()
+	result, err := tx.Txx.ExecContext(tx.ctx, query)
+	tx.observeExec(op, query, nil, startTs, result, err)
+	if err != nil {
+		tx.setQueryErr(op, query, nil, err)
+		return false
+	}
+	return true
+}
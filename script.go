@@ -0,0 +1,107 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Splits 'script' into statements on semicolons and runs each one with Exec (in
+// order).  Semicolons inside of quoted strings ('...', "...", `...`), Postgres
+// dollar-quoted strings ($$...$$ or $tag$...$tag$), and comments (-- and /* */)
+// do not end a statement.  Empty statements are skipped.
+//
+// Stops at the first failing statement.  tx.Err is set to an error that includes
+// the (1-based) index of the statement and wraps the underlying error.
+func (tx *TxWrap) ExecScript(script string) {
+	if tx.checkErr() {
+		return
+	}
+	for idx, stmt := range splitSQLScript(script) {
+		tx.Exec(stmt)
+		if tx.Err != nil {
+			tx.Err = fmt.Errorf("ExecScript statement #%d: %w", idx+1, tx.Err)
+			return
+		}
+	}
+}
+
+// splits a SQL script into statements (see ExecScript).  statements are trimmed,
+// and statements that contain only whitespace or comments are dropped.
+func splitSQLScript(script string) []string {
+	var rtn []string
+	start := 0
+	hasContent := false
+	addStmt := func(end int) {
+		if hasContent {
+			rtn = append(rtn, strings.TrimSpace(script[start:end]))
+		}
+		start = end + 1
+		hasContent = false
+	}
+	for i := 0; i < len(script); i++ {
+		ch := script[i]
+		switch {
+		case ch == ';':
+			addStmt(i)
+		case ch == '-' && strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end == -1 {
+				i = len(script)
+			} else {
+				i += end
+			}
+		case ch == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end == -1 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			// doubled quotes ('') are an escaped quote, handled by re-entering this case
+			hasContent = true
+			end := strings.IndexByte(script[i+1:], ch)
+			if end == -1 {
+				i = len(script)
+			} else {
+				i += end + 1
+			}
+		case ch == '$':
+			hasContent = true
+			tag, ok := dollarQuoteTag(script[i:])
+			if !ok {
+				continue
+			}
+			end := strings.Index(script[i+len(tag):], tag)
+			if end == -1 {
+				i = len(script)
+			} else {
+				i += len(tag) + end + len(tag) - 1
+			}
+		case ch != ' ' && ch != '\t' && ch != '\n' && ch != '\r':
+			hasContent = true
+		}
+	}
+	addStmt(len(script))
+	return rtn
+}
+
+// returns the opening tag of a Postgres dollar-quoted string ($$ or $tag$) if 's'
+// starts with one.  $1 style placeholders are not tags.
+func dollarQuoteTag(s string) (string, bool) {
+	for i := 1; i < len(s); i++ {
+		ch := s[i]
+		if ch == '$' {
+			return s[:i+1], true
+		}
+		isLetter := (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
+		isDigit := ch >= '0' && ch <= '9'
+		if !isLetter && !(isDigit && i > 1) {
+			return "", false
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,45 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Sets a configuration parameter for the rest of the transaction (SET LOCAL), e.g.
+// a custom setting used by row-level-security policies:
+//
+//	tx.SetLocal("app.current_user_id", strconv.FormatInt(userId, 10))
+//
+// Runs set_config(name, value, true) so both the name and the value are passed as
+// query arguments (no SQL formatting).  The setting is reset when the transaction
+// commits or rolls back (a savepoint rollback also reverts it).  Only supported on
+// Postgres, other drivers set an error on tx.
+func (tx *TxWrap) SetLocal(name string, value string) {
+	if !tx.checkPostgresDriver("SetLocal") {
+		return
+	}
+	// run as a query (not Exec) so it also works in read-only transactions
+	tx.GetString(`SELECT set_config($1, $2, true)`, name, value)
+}
+
+// Sets the role for the rest of the transaction (SET LOCAL ROLE), see SetLocal
+func (tx *TxWrap) SetLocalRole(role string) {
+	tx.SetLocal("role", role)
+}
+
+// sets an error on tx (and returns false) if the driver is not Postgres
+func (tx *TxWrap) checkPostgresDriver(fnName string) bool {
+	if tx.checkErr() {
+		return false
+	}
+	driverName := tx.queryer().DriverName()
+	if sqlx.BindType(driverName) != sqlx.DOLLAR {
+		tx.SetErr(fmt.Errorf("txwrap %s is not supported for driver %q", fnName, driverName))
+		return false
+	}
+	return true
+}
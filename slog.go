@@ -0,0 +1,117 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+)
+
+type slogKey struct{}
+
+// Structured logging with log/slog.  Every transaction gets a random "tx_id"
+// attribute, which is added to all of its log records (and to tx.Logger(), so code
+// inside the transaction can correlate its own logs).  Logs:
+//   - "txwrap begin", "txwrap commit", and "txwrap rollback" for each (outer)
+//     transaction, with the duration, attempts, and error
+//   - "txwrap retry" (Info) before each retry, with the error that caused it
+//   - "txwrap query" for every DB call, with the op, query, args (redacted if
+//     WithRedact is set), duration, rows affected, and error
+//
+// Successful transactions and queries are logged at Debug level, failed queries
+// at Error level, and rolled back transactions at Warn level (sql.ErrNoRows is not
+// considered a failure).
+func WithSlog(logger *slog.Logger) TxOption {
+	return func(opts *txOpts) {
+		opts.slogger = logger
+		WithTxHook(makeSlogTxHook(logger))(opts)
+		WithQueryHook(makeSlogQueryHook(logger))(opts)
+	}
+}
+
+// Returns a logger for code running inside of the transaction.  With WithSlog it
+// includes the transaction's "tx_id" attribute, otherwise returns slog.Default().
+func (tx *TxWrap) Logger() *slog.Logger {
+	var baseLogger *slog.Logger
+	if tx.opts != nil {
+		baseLogger = tx.opts.slogger
+	}
+	return getContextLogger(tx.ctx, baseLogger)
+}
+
+func getContextLogger(ctx context.Context, baseLogger *slog.Logger) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(slogKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	if baseLogger != nil {
+		return baseLogger
+	}
+	return slog.Default()
+}
+
+func makeTxId() string {
+	return fmt.Sprintf("%016x", rand.Uint64())
+}
+
+func makeSlogTxHook(logger *slog.Logger) TxHook {
+	return func(ctx context.Context) (context.Context, func(info TxInfo)) {
+		txLogger := logger.With(slog.String("tx_id", makeTxId()))
+		ctx = context.WithValue(ctx, slogKey{}, txLogger)
+		txLogger.DebugContext(ctx, "txwrap begin")
+		return ctx, func(info TxInfo) {
+			attrs := []slog.Attr{
+				slog.Duration("duration", info.Duration),
+				slog.Int("attempts", info.Attempts),
+			}
+			level := slog.LevelDebug
+			if info.Err != nil {
+				attrs = append(attrs, slog.Any("err", info.Err))
+				level = slog.LevelWarn
+			}
+			msg := "txwrap rollback"
+			if info.Committed {
+				msg = "txwrap commit"
+			}
+			txLogger.LogAttrs(ctx, level, msg, attrs...)
+		}
+	}
+}
+
+func makeSlogQueryHook(logger *slog.Logger) QueryHook {
+	return func(ctx context.Context, info QueryInfo) {
+		attrs := []slog.Attr{
+			slog.String("op", info.Op),
+			slog.String("query", info.Query),
+			slog.Duration("duration", info.Duration),
+		}
+		if len(info.Args) > 0 {
+			attrs = append(attrs, slog.Any("args", info.Args))
+		}
+		if info.RowsAffected >= 0 {
+			attrs = append(attrs, slog.Int64("rows", info.RowsAffected))
+		}
+		level := slog.LevelDebug
+		if info.Err != nil {
+			attrs = append(attrs, slog.Any("err", info.Err))
+			if !errors.Is(info.Err, sql.ErrNoRows) {
+				level = slog.LevelError
+			}
+		}
+		getContextLogger(ctx, logger).LogAttrs(ctx, level, "txwrap query", attrs...)
+	}
+}
+
+// logs a retry with WithSlog (called before the retry attempt runs)
+func (opts *txOpts) logRetry(ctx context.Context, attempt int, err error) {
+	if opts.slogger == nil {
+		return
+	}
+	getContextLogger(ctx, opts.slogger).LogAttrs(ctx, slog.LevelInfo, "txwrap retry", slog.Int("attempt", attempt), slog.Any("err", err))
+}
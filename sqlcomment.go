@@ -0,0 +1,160 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type commentTagsKey struct{}
+
+// Appends a sqlcommenter (https://google.github.io/sqlcommenter/) comment to every
+// query run through the TxWrap, e.g.
+//
+//	SELECT * FROM users WHERE id = $1 /*app='api',route='%2Fusers%2F%3Aid',traceparent='00-...'*/
+//
+// so queries in pg_stat_activity (or the slow query log) can be matched to the
+// request that ran them.  The tags come from 'tagsFn' (may be nil), merged with any
+// tags added to the Context with ContextWithCommentTags (which take precedence).
+// Keys and values are URL encoded and sorted by key.  Queries that already contain
+// a comment are not modified.  For an OpenTelemetry traceparent tag see
+// txwrapotel.CommentTags.
+//
+// Note that with WithStmtCache, queries with different tag values are prepared
+// separately.  Statements prepared with Preparex do not get a comment.
+func WithSQLCommenter(tagsFn func(ctx context.Context) map[string]string) TxOption {
+	return func(opts *txOpts) {
+		opts.sqlCommenter = true
+		opts.commentTagsFn = tagsFn
+	}
+}
+
+// Returns a Context with sqlcommenter tags (e.g. route, request id) that are added
+// to every query when the WithSQLCommenter option is set.  Tags are merged with the
+// tags already on 'ctx'.
+func ContextWithCommentTags(ctx context.Context, tags map[string]string) context.Context {
+	merged := make(map[string]string)
+	if oldTags, ok := ctx.Value(commentTagsKey{}).(map[string]string); ok {
+		for key, val := range oldTags {
+			merged[key] = val
+		}
+	}
+	for key, val := range tags {
+		merged[key] = val
+	}
+	return context.WithValue(ctx, commentTagsKey{}, merged)
+}
+
+// wraps the query handle with WithSQLCommenter (called when a TxWrap is created)
+func (tx *TxWrap) setupSQLCommenter() {
+	if tx.opts == nil || !tx.opts.sqlCommenter {
+		return
+	}
+	tx.ext = commentExt{ext: tx.queryer(), tagsFn: tx.opts.commentTagsFn}
+}
+
+// sqlx.ExtContext that adds a sqlcommenter comment to queries
+type commentExt struct {
+	ext    sqlx.ExtContext
+	tagsFn func(ctx context.Context) map[string]string
+}
+
+var _ sqlx.ExtContext = commentExt{}
+
+func (c commentExt) addComment(ctx context.Context, query string) string {
+	if strings.Contains(query, "/*") || strings.Contains(query, "--") {
+		return query
+	}
+	tags := make(map[string]string)
+	if c.tagsFn != nil {
+		for key, val := range c.tagsFn(ctx) {
+			tags[key] = val
+		}
+	}
+	if ctxTags, ok := ctx.Value(commentTagsKey{}).(map[string]string); ok {
+		for key, val := range ctxTags {
+			tags[key] = val
+		}
+	}
+	comment := formatSQLComment(tags)
+	if comment == "" {
+		return query
+	}
+	trimmed := strings.TrimRight(query, " \t\r\n")
+	if strings.HasSuffix(trimmed, ";") {
+		return strings.TrimSuffix(trimmed, ";") + " " + comment + ";"
+	}
+	return trimmed + " " + comment
+}
+
+// formats tags as a sqlcommenter comment (tags with an empty value are skipped)
+func formatSQLComment(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key, val := range tags {
+		if key != "" && val != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	var buf strings.Builder
+	buf.WriteString("/*")
+	for idx, key := range keys {
+		if idx > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(escapeCommentStr(url.QueryEscape(key)))
+		buf.WriteString("='")
+		buf.WriteString(escapeCommentStr(url.QueryEscape(tags[key])))
+		buf.WriteString("'")
+	}
+	buf.WriteString("*/")
+	return buf.String()
+}
+
+// url.QueryEscape encodes spaces as +, sqlcommenter uses %20.  ' is already
+// encoded (as %27), so no other escaping is needed.
+func escapeCommentStr(s string) string {
+	return strings.ReplaceAll(s, "+", "%20")
+}
+
+func (c commentExt) unwrapExt(ctx context.Context, query string) (sqlx.ExtContext, string) {
+	return c.ext, c.addComment(ctx, query)
+}
+
+func (c commentExt) DriverName() string {
+	return c.ext.DriverName()
+}
+
+func (c commentExt) Rebind(query string) string {
+	return c.ext.Rebind(query)
+}
+
+func (c commentExt) BindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	return c.ext.BindNamed(query, arg)
+}
+
+func (c commentExt) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.ext.ExecContext(ctx, c.addComment(ctx, query), args...)
+}
+
+func (c commentExt) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.ext.QueryContext(ctx, c.addComment(ctx, query), args...)
+}
+
+func (c commentExt) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	return c.ext.QueryxContext(ctx, c.addComment(ctx, query), args...)
+}
+
+func (c commentExt) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	return c.ext.QueryRowxContext(ctx, c.addComment(ctx, query), args...)
+}
@@ -0,0 +1,60 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// Returns true if 'err' is SQLITE_BUSY or SQLITE_LOCKED ("database is locked"),
+// including the extended codes (e.g. SQLITE_BUSY_SNAPSHOT).  The statement (or
+// commit) did not run and the transaction can be retried.
+func IsSQLiteBusy(err error) bool {
+	code, ok := getSQLiteCode(err)
+	if !ok {
+		return false
+	}
+	primaryCode := code & 0xff
+	return primaryCode == 5 || primaryCode == 6 // SQLITE_BUSY, SQLITE_LOCKED
+}
+
+// Serializes write transactions (transactions that are not read-only) started on
+// the same *sql.DB in this process: a transaction waits (or fails with the ctx
+// error) until the previous write transaction has committed or rolled back, before
+// it begins.  For single-process SQLite deployments, so concurrent writers wait in
+// the process instead of failing with SQLITE_BUSY (combine with WithRetry for
+// writers in other processes).  Each attempt of a retried transaction takes the
+// lock separately.  Has no effect for transactions started with WithTxConn.
+//
+// A write transaction must not start another write transaction on the same DB with
+// a different Context while it is running (it would wait forever).
+func WithSerializedWrites() TxOption {
+	return func(opts *txOpts) {
+		opts.serializedWrites = true
+	}
+}
+
+// write locks for WithSerializedWrites, map of *sql.DB => chan struct{} (capacity 1)
+var writeLocks sync.Map
+
+// waits for the write lock for 'db' if needed, returns the function to release it
+func (opts *txOpts) lockWrites(ctx context.Context, db txBeginner, sqlOpts *sql.TxOptions) (func(), error) {
+	if !opts.serializedWrites || (sqlOpts != nil && sqlOpts.ReadOnly) {
+		return func() {}, nil
+	}
+	dbs := beginnerDBs(db)
+	if len(dbs) == 0 {
+		return func() {}, nil
+	}
+	lockVal, _ := writeLocks.LoadOrStore(dbs[0], make(chan struct{}, 1))
+	lockCh := lockVal.(chan struct{})
+	select {
+	case lockCh <- struct{}{}:
+		return func() { <-lockCh }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
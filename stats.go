@@ -0,0 +1,58 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Statistics for a transaction.  Counts and durations are totals over all attempts
+// (if the transaction was retried).
+type TxStats struct {
+	Statements     int           // number of DB calls made through the TxWrap
+	QueryDuration  time.Duration // total time spent in DB calls
+	RowsAffected   int64         // total rows affected by Exec calls (when available)
+	Retries        int           // number of retries (Attempts - 1)
+	CommitDuration time.Duration // time spent in Commit
+	Duration       time.Duration // total time, including all attempts and OnCommit callbacks
+}
+
+// Calls 'fn' with the TxStats once the transaction completes (committed or not)
+func WithStatsCallback(fn func(ctx context.Context, stats TxStats)) TxOption {
+	return func(opts *txOpts) {
+		opts.statsFns = append(opts.statsFns, fn)
+	}
+}
+
+// Same as WithTx, but also returns the TxStats for the transaction.  If nested inside
+// of another transaction, the returned stats are empty (the outer transaction owns
+// the stats).
+func WithTxStats(ctx context.Context, db *sqlx.DB, fn func(tx *TxWrap) error, opts ...TxOption) (TxStats, error) {
+	var rtn TxStats
+	statsOpt := WithStatsCallback(func(ctx context.Context, stats TxStats) {
+		rtn = stats
+	})
+	err := WithTx(ctx, db, fn, append(opts, statsOpt)...)
+	return rtn, err
+}
+
+func (stats *TxStats) addQuery(dur time.Duration, rowsAffected int64) {
+	stats.Statements++
+	stats.QueryDuration += dur
+	if rowsAffected > 0 {
+		stats.RowsAffected += rowsAffected
+	}
+}
+
+func (opts *txOpts) reportStats(ctx context.Context, stats *TxStats) {
+	if stats == nil {
+		return
+	}
+	for _, fn := range opts.statsFns {
+		fn(ctx, *stats)
+	}
+}
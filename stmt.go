@@ -0,0 +1,149 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Error-latching wrapper around *sqlx.Stmt returned by TxWrap.Preparex.  Exec, Get,
+// and Select work like the TxWrap methods of the same name (errors are set on the
+// TxWrap, calls are skipped once the TxWrap has an error, QueryHooks are called).
+// Statements that are still open when the transaction finishes are closed automatically.
+//
+//	stmt := tx.Preparex(`INSERT INTO tags (name) VALUES (?)`)
+//	for _, name := range names {
+//	    stmt.Exec(name)
+//	}
+type TxStmt struct {
+	stmt  *sqlx.Stmt // nil if the prepare failed (or the statement is closed)
+	tx    *TxWrap
+	query string
+}
+
+// Prepares 'query' and returns the (managed) statement.  Never returns nil, if there
+// is an error the returned TxStmt does nothing.  With WithDryRun, TxStmt.Exec reports
+// the statement instead of running it.
+func (tx *TxWrap) Preparex(query string) *TxStmt {
+	rtn := &TxStmt{tx: tx, query: query}
+	if tx.checkErr() {
+		return rtn
+	}
+	preparer, err := tx.preparer()
+	if err != nil {
+		tx.setQueryErr("Preparex", query, nil, err)
+		return rtn
+	}
+	if tx.opts != nil && tx.opts.autoRebind {
+		query = tx.Rebind(query)
+	}
+	startTs := tx.now()
+	stmt, err := sqlx.PreparexContext(tx.ctx, preparer, query)
+	tx.observeQuery("Preparex", query, nil, startTs, err)
+	if err != nil {
+		tx.setQueryErr("Preparex", query, nil, err)
+		return rtn
+	}
+	rtn.stmt = stmt
+	tx.openStmts = append(tx.openStmts, rtn)
+	return rtn
+}
+
+// returns the underlying *sqlx.Tx (or *sqlx.DB for a DBWrap) for preparing statements
+func (tx *TxWrap) preparer() (sqlx.PreparerContext, error) {
+	if tx.Txx != nil {
+		return tx.Txx, nil
+	}
+	ext, _ := tx.baseExt("")
+	preparer, ok := ext.(sqlx.PreparerContext)
+	if !ok {
+		return nil, fmt.Errorf("txwrap Preparex, cannot prepare statements on %T", ext)
+	}
+	return preparer, nil
+}
+
+func (s *TxStmt) Exec(args ...interface{}) sql.Result {
+	tx := s.tx
+	if s.stmt == nil || tx.checkErr() {
+		return errResult{tx.Err}
+	}
+	startTs := tx.now()
+	var result sql.Result
+	var err error
+	tx.ClearMemo()
+	if tx.isReadOnly() {
+		err = ErrReadOnlyTx
+	} else if tx.opts != nil && tx.opts.dryRun != nil {
+		result, err = dryRunExt{opts: tx.opts}.ExecContext(tx.ctx, s.query, args...)
+	} else {
+		result, err = s.stmt.ExecContext(tx.ctx, args...)
+	}
+	tx.observeExec("StmtExec", s.query, args, startTs, result, err)
+	if err != nil {
+		tx.setQueryErr("StmtExec", s.query, args, err)
+		return errResult{err}
+	}
+	return result
+}
+
+// Same as TxWrap.Get, returns false if there are no rows (or there is an error).
+// sql.ErrNoRows is only an error with WithStrictNoRows.
+func (s *TxStmt) Get(dest interface{}, args ...interface{}) bool {
+	tx := s.tx
+	if s.stmt == nil || tx.checkErr() {
+		return false
+	}
+	startTs := tx.now()
+	err := s.stmt.GetContext(tx.ctx, dest, args...)
+	tx.observeQuery("StmtGet", s.query, args, startTs, err)
+	if err == sql.ErrNoRows {
+		if tx.isStrictNoRows() {
+			tx.setQueryErr("StmtGet", s.query, args, noRowsError(s.query))
+		}
+		return false
+	}
+	if err != nil {
+		tx.setQueryErr("StmtGet", s.query, args, err)
+		return false
+	}
+	return true
+}
+
+func (s *TxStmt) Select(dest interface{}, args ...interface{}) {
+	tx := s.tx
+	if s.stmt == nil || tx.checkErr() {
+		return
+	}
+	startTs := tx.now()
+	err := s.stmt.SelectContext(tx.ctx, dest, args...)
+	tx.observeQuery("StmtSelect", s.query, args, startTs, err)
+	if err != nil {
+		tx.setQueryErr("StmtSelect", s.query, args, err)
+	}
+}
+
+// Closes the statement.  Safe to call multiple times.
+func (s *TxStmt) Close() {
+	if s.stmt == nil {
+		return
+	}
+	s.stmt.Close()
+	s.stmt = nil
+	for idx, openStmt := range s.tx.openStmts {
+		if openStmt == s {
+			s.tx.openStmts = append(s.tx.openStmts[:idx], s.tx.openStmts[idx+1:]...)
+			break
+		}
+	}
+}
+
+// closes any TxStmts left open by Preparex (called before commit/rollback)
+func (tx *TxWrap) closeOpenStmts() {
+	for len(tx.openStmts) > 0 {
+		tx.openStmts[0].Close()
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Caches prepared statements (by query text) for the lifetime of the transaction so
+// queries that run many times in one transaction are only parsed once.  Applies to
+// all TxWrap query methods.  Disabled by default, enable it per transaction or for all
+// transactions with SetDefaultOptions(WithStmtCache(true)) (and disable it for a
+// specific transaction with WithStmtCache(false)).
+func WithStmtCache(enabled bool) TxOption {
+	return func(opts *txOpts) {
+		opts.stmtCache = enabled
+	}
+}
+
+// sqlx.ExtContext that runs queries using cached prepared statements
+type stmtCache struct {
+	tx    *sqlx.Tx
+	stmts map[string]*sqlx.Stmt
+}
+
+var _ sqlx.ExtContext = (*stmtCache)(nil)
+
+func makeStmtCache(tx *sqlx.Tx) *stmtCache {
+	return &stmtCache{tx: tx, stmts: make(map[string]*sqlx.Stmt)}
+}
+
+func (c *stmtCache) getStmt(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	stmt, ok := c.stmts[query]
+	if ok {
+		return stmt, nil
+	}
+	stmt, err := c.tx.PreparexContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	c.stmts[query] = stmt
+	return stmt, nil
+}
+
+func (c *stmtCache) close() {
+	for _, stmt := range c.stmts {
+		stmt.Close()
+	}
+	c.stmts = nil
+}
+
+func (c *stmtCache) DriverName() string {
+	return c.tx.DriverName()
+}
+
+func (c *stmtCache) Rebind(query string) string {
+	return c.tx.Rebind(query)
+}
+
+func (c *stmtCache) BindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	return c.tx.BindNamed(query, arg)
+}
+
+func (c *stmtCache) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := c.getStmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.ExecContext(ctx, args...)
+}
+
+func (c *stmtCache) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := c.getStmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.QueryContext(ctx, args...)
+}
+
+func (c *stmtCache) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	stmt, err := c.getStmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.QueryxContext(ctx, args...)
+}
+
+func (c *stmtCache) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	stmt, err := c.getStmt(ctx, query)
+	if err != nil {
+		// sqlx.Row cannot be constructed with an error, so fall back to the tx.
+		// TxWrap calls prepareStmt before QueryRowxContext so prepare errors
+		// are normally reported before we get here.
+		return c.tx.QueryRowxContext(ctx, query, args...)
+	}
+	return stmt.QueryRowxContext(ctx, args...)
+}
+
+// implemented by the sqlx.ExtContext wrappers (WithAutoRebind, WithDryRun, etc.).
+// returns the wrapped ExtContext and the query as it will be passed to it.
+type extWrapper interface {
+	unwrapExt(ctx context.Context, query string) (sqlx.ExtContext, string)
+}
+
+// unwraps tx.ext down to the base handle (the statement cache, *sqlx.Tx, or *sqlx.DB)
+func (tx *TxWrap) baseExt(query string) (sqlx.ExtContext, string) {
+	ext := tx.queryer()
+	for {
+		wrapper, ok := ext.(extWrapper)
+		if !ok {
+			return ext, query
+		}
+		ext, query = wrapper.unwrapExt(tx.ctx, query)
+	}
+}
+
+// with the statement cache enabled, prepares 'query' so prepare errors are
+// returned directly (QueryRowxContext cannot return them)
+func (tx *TxWrap) prepareStmt(query string) error {
+	if tx.opts == nil || !tx.opts.stmtCache {
+		// skip unwrapping (rewrite functions and SQL comments allocate)
+		return nil
+	}
+	ext, query := tx.baseExt(query)
+	cache, ok := ext.(*stmtCache)
+	if !ok {
+		return nil
+	}
+	_, err := cache.getStmt(tx.ctx, query)
+	return err
+}
@@ -0,0 +1,143 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Sets a default timeout for each statement (Exec, Get, Select, etc.), so one slow
+// query cannot use up the whole transaction (see WithTxTimeout for a deadline on
+// the whole transaction).  When a statement times out it fails with
+// context.DeadlineExceeded (set on tx.Err like any other DB error).  Override the
+// timeout for part of a transaction with tx.WithStatementTimeout.
+//
+// For queries that return rows, the timeout covers reading the rows as well.  With
+// NewDBWrap, call DBWrap.Close when done to release these timeouts.
+func WithStatementTimeout(d time.Duration) TxOption {
+	return func(opts *txOpts) {
+		opts.stmtTimeout = d
+	}
+}
+
+// Runs 'fn' with a statement timeout of 'd' (0 for no timeout), overriding the
+// WithStatementTimeout option.  The previous timeout is restored when 'fn' returns.
+//
+//	tx.WithStatementTimeout(30*time.Second, func() {
+//	    tx.Exec(`REFRESH MATERIALIZED VIEW report_totals`)
+//	})
+func (tx *TxWrap) WithStatementTimeout(d time.Duration, fn func()) {
+	oldTimeout := tx.stmtTimeout
+	tx.stmtTimeout = d
+	defer func() {
+		tx.stmtTimeout = oldTimeout
+	}()
+	if _, ok := tx.ext.(stmtTimeoutExt); !ok {
+		oldExt := tx.ext
+		tx.ext = stmtTimeoutExt{ext: tx.queryer(), tx: tx}
+		defer func() {
+			tx.ext = oldExt
+		}()
+	}
+	fn()
+}
+
+// wraps the query handle with WithStatementTimeout (called when a TxWrap is created)
+func (tx *TxWrap) setupStatementTimeout() {
+	if tx.opts == nil || tx.opts.stmtTimeout <= 0 {
+		return
+	}
+	tx.stmtTimeout = tx.opts.stmtTimeout
+	tx.ext = stmtTimeoutExt{ext: tx.queryer(), tx: tx}
+}
+
+// a statement timeout context for a query that returns rows
+type stmtTimeoutCtx struct {
+	ctx      context.Context
+	cancelFn context.CancelFunc
+}
+
+// cancels the statement timeout contexts of queries that returned rows (called when
+// the transaction ends, or from DBWrap.Close)
+func (tx *TxWrap) cancelStmtTimeouts() {
+	for _, sctx := range tx.stmtCtxs {
+		sctx.cancelFn()
+	}
+	tx.stmtCtxs = nil
+}
+
+// sqlx.ExtContext that runs each statement with the TxWrap's statement timeout
+type stmtTimeoutExt struct {
+	ext sqlx.ExtContext
+	tx  *TxWrap
+}
+
+var _ sqlx.ExtContext = stmtTimeoutExt{}
+
+// for queries that return rows, the context cannot be canceled until the rows are
+// read, so the contexts are saved and canceled when the transaction ends (contexts
+// that have already timed out are dropped as more are added)
+func (s stmtTimeoutExt) rowsContext(ctx context.Context) context.Context {
+	if s.tx.stmtTimeout <= 0 {
+		return ctx
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, s.tx.stmtTimeout)
+	if len(s.tx.stmtCtxs) >= 64 && len(s.tx.stmtCtxs) == cap(s.tx.stmtCtxs) {
+		s.tx.pruneStmtTimeouts()
+	}
+	s.tx.stmtCtxs = append(s.tx.stmtCtxs, stmtTimeoutCtx{ctx: ctx, cancelFn: cancelFn})
+	return ctx
+}
+
+func (tx *TxWrap) pruneStmtTimeouts() {
+	live := tx.stmtCtxs[:0]
+	for _, sctx := range tx.stmtCtxs {
+		if sctx.ctx.Err() == nil {
+			live = append(live, sctx)
+		}
+	}
+	clear(tx.stmtCtxs[len(live):])
+	tx.stmtCtxs = live
+}
+
+func (s stmtTimeoutExt) unwrapExt(ctx context.Context, query string) (sqlx.ExtContext, string) {
+	return s.ext, query
+}
+
+func (s stmtTimeoutExt) DriverName() string {
+	return s.ext.DriverName()
+}
+
+func (s stmtTimeoutExt) Rebind(query string) string {
+	return s.ext.Rebind(query)
+}
+
+func (s stmtTimeoutExt) BindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	return s.ext.BindNamed(query, arg)
+}
+
+func (s stmtTimeoutExt) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if s.tx.stmtTimeout <= 0 {
+		return s.ext.ExecContext(ctx, query, args...)
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, s.tx.stmtTimeout)
+	defer cancelFn()
+	return s.ext.ExecContext(ctx, query, args...)
+}
+
+func (s stmtTimeoutExt) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.ext.QueryContext(s.rowsContext(ctx), query, args...)
+}
+
+func (s stmtTimeoutExt) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	return s.ext.QueryxContext(s.rowsContext(ctx), query, args...)
+}
+
+func (s stmtTimeoutExt) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	return s.ext.QueryRowxContext(s.rowsContext(ctx), query, args...)
+}
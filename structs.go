@@ -0,0 +1,131 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// A column mapped from a struct field (using db tags, same rules as sqlx)
+type structField struct {
+	Column string
+	Index  []int
+	name   string // struct field name
+}
+
+// Returns the columns for struct type 't'.  Uses the `db` tag (or the name from
+// 'mapper' if there is no tag, so columns match what Get and Select scan into),
+// skips `db:"-"` and unexported fields, and flattens untagged embedded structs.
+func getStructFields(mapper *reflectx.Mapper, t reflect.Type) []structField {
+	fields := collectStructFields(t)
+	var typeMap *reflectx.StructMap
+	for idx := range fields {
+		if fields[idx].Column != "" {
+			continue
+		}
+		if typeMap == nil {
+			typeMap = mapper.TypeMap(t)
+		}
+		if fi := typeMap.GetByTraversal(fields[idx].Index); fi != nil {
+			fields[idx].Column = fi.Name
+		} else {
+			fields[idx].Column = sqlx.NameMapper(fields[idx].name)
+		}
+	}
+	return fields
+}
+
+// returns the fields of 't', Column is empty for untagged fields
+func collectStructFields(t reflect.Type) []structField {
+	var rtn []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("db")
+		tag, _, _ = strings.Cut(tag, ",")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && !hasTag {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for _, sf := range collectStructFields(ft) {
+					sf.Index = append([]int{i}, sf.Index...)
+					rtn = append(rtn, sf)
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		rtn = append(rtn, structField{Column: tag, Index: f.Index, name: f.Name})
+	}
+	return rtn
+}
+
+var defaultMapperOnce sync.Once
+var defaultMapper *reflectx.Mapper
+
+// returns the mapper used by the sqlx handle (so struct columns follow a custom
+// db.MapperFunc), or the sqlx default mapper
+func (tx *TxWrap) mapper() *reflectx.Mapper {
+	if tx.Txx != nil && tx.Txx.Mapper != nil {
+		return tx.Txx.Mapper
+	}
+	if tx.dbMapper != nil {
+		return tx.dbMapper
+	}
+	defaultMapperOnce.Do(func() {
+		defaultMapper = reflectx.NewMapperFunc("db", sqlx.NameMapper)
+	})
+	return defaultMapper
+}
+
+// returns the value of the field (nil if it is inside a nil embedded pointer)
+func (sf structField) value(v reflect.Value) interface{} {
+	for _, idx := range sf.Index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v.Interface()
+}
+
+// derefs pointers and returns the struct value of 'v' (or an error)
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return reflect.Value{}, fmt.Errorf("nil %T passed, must be a struct", v)
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("invalid type %T, must be a struct", v)
+	}
+	return rv, nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// structs are scanned by db tags unless they can scan a single column themselves
+func isStructScanType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PointerTo(t).Implements(scannerType)
+}
@@ -0,0 +1,186 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Option for InsertStruct and UpdateStruct
+type StructOption func(opts *structOpts)
+
+type structOpts struct {
+	omitZero bool
+	omitCols map[string]bool
+	onlyCols map[string]bool
+	returnId string
+}
+
+// Skips columns whose struct field has the zero value (so database defaults apply)
+func OmitZero() StructOption {
+	return func(opts *structOpts) {
+		opts.omitZero = true
+	}
+}
+
+// Skips the given columns (e.g. auto-generated or default columns)
+func OmitColumns(columns ...string) StructOption {
+	return func(opts *structOpts) {
+		if opts.omitCols == nil {
+			opts.omitCols = make(map[string]bool)
+		}
+		for _, col := range columns {
+			opts.omitCols[col] = true
+		}
+	}
+}
+
+// Only uses the given columns (all other struct columns are skipped)
+func OnlyColumns(columns ...string) StructOption {
+	return func(opts *structOpts) {
+		if opts.onlyCols == nil {
+			opts.onlyCols = make(map[string]bool)
+		}
+		for _, col := range columns {
+			opts.onlyCols[col] = true
+		}
+	}
+}
+
+// For InsertStruct (ignored by UpdateStruct).  'idColumn' is omitted from the insert and its generated value
+// is returned.  Uses RETURNING for Postgres, otherwise LastInsertId.
+func ReturningId(idColumn string) StructOption {
+	return func(opts *structOpts) {
+		opts.returnId = idColumn
+	}
+}
+
+func makeStructOpts(opts []StructOption) *structOpts {
+	rtn := &structOpts{}
+	for _, opt := range opts {
+		opt(rtn)
+	}
+	return rtn
+}
+
+func (opts *structOpts) skipColumn(col string, val interface{}) bool {
+	if opts.omitCols[col] || col == opts.returnId {
+		return true
+	}
+	if opts.onlyCols != nil && !opts.onlyCols[col] {
+		return true
+	}
+	return opts.omitZero && (val == nil || reflect.ValueOf(val).IsZero())
+}
+
+// Inserts struct 'v' (or a pointer to a struct) into 'table'.  Columns are mapped
+// from the struct's db tags (like BulkInsert), opts can skip columns (OmitZero,
+// OmitColumns, OnlyColumns).  Returns the generated id if ReturningId is set,
+// otherwise 0.  'table' and the column names are not quoted or escaped, they must
+// not come from user input.
+func (tx *TxWrap) InsertStruct(table string, v interface{}, opts ...StructOption) int64 {
+	if tx.checkErr() {
+		return 0
+	}
+	sopts := makeStructOpts(opts)
+	rowVal, err := structValue(v)
+	if err != nil {
+		tx.Err = fmt.Errorf("InsertStruct %s: %w", table, err)
+		return 0
+	}
+	var columns []string
+	var args []interface{}
+	for _, f := range getStructFields(tx.mapper(), rowVal.Type()) {
+		val := f.value(rowVal)
+		if sopts.skipColumn(f.Column, val) {
+			continue
+		}
+		columns = append(columns, f.Column)
+		args = append(args, val)
+	}
+	var query string
+	if len(columns) == 0 && strings.Contains(tx.queryer().DriverName(), "mysql") {
+		query = fmt.Sprintf("INSERT INTO %s () VALUES ()", table)
+	} else if len(columns) == 0 {
+		query = fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", table)
+	} else {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+		query = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), placeholders)
+	}
+	query = tx.queryer().Rebind(query)
+	if sopts.returnId == "" {
+		tx.Exec(query, args...)
+		return 0
+	}
+	if sqlx.BindType(tx.queryer().DriverName()) == sqlx.DOLLAR {
+		var id int64
+		tx.GetRequired(&id, query+" RETURNING "+sopts.returnId, args...)
+		return id
+	}
+	return tx.ExecInsertId(query, args...)
+}
+
+// Updates the row(s) in 'table' matching 'whereCols' (compared to the struct's values
+// for those columns) with the other columns of struct 'v'.  opts select the columns
+// that are set (OmitZero, OmitColumns, OnlyColumns), they do not apply to 'whereCols'.
+// Returns the number of rows affected (see ExecAffected).
+//
+//	tx.UpdateStruct("users", user, []string{"id"}, txwrap.OnlyColumns("name", "email"))
+//
+// 'table' and the column names are not quoted or escaped, they must not come from
+// user input.
+func (tx *TxWrap) UpdateStruct(table string, v interface{}, whereCols []string, opts ...StructOption) int64 {
+	if tx.checkErr() {
+		return 0
+	}
+	if len(whereCols) == 0 {
+		tx.Err = fmt.Errorf("UpdateStruct %s: no where columns", table)
+		return 0
+	}
+	sopts := makeStructOpts(opts)
+	rowVal, err := structValue(v)
+	if err != nil {
+		tx.Err = fmt.Errorf("UpdateStruct %s: %w", table, err)
+		return 0
+	}
+	isWhereCol := make(map[string]bool)
+	for _, col := range whereCols {
+		isWhereCol[col] = true
+	}
+	whereVals := make(map[string]interface{})
+	var sets []string
+	var args []interface{}
+	for _, f := range getStructFields(tx.mapper(), rowVal.Type()) {
+		val := f.value(rowVal)
+		if isWhereCol[f.Column] {
+			whereVals[f.Column] = val
+			continue
+		}
+		if sopts.skipColumn(f.Column, val) {
+			continue
+		}
+		sets = append(sets, f.Column+" = ?")
+		args = append(args, val)
+	}
+	if len(sets) == 0 {
+		tx.Err = fmt.Errorf("UpdateStruct %s: no columns to update", table)
+		return 0
+	}
+	wheres := make([]string, len(whereCols))
+	for i, col := range whereCols {
+		val, ok := whereVals[col]
+		if !ok {
+			tx.Err = fmt.Errorf("UpdateStruct %s: where column %q not found in %v", table, col, rowVal.Type())
+			return 0
+		}
+		wheres[i] = col + " = ?"
+		args = append(args, val)
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", table, strings.Join(sets, ", "), strings.Join(wheres, " AND "))
+	return tx.ExecAffected(tx.queryer().Rebind(query), args...)
+}
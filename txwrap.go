@@ -7,9 +7,14 @@ package txwrap
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"reflect"
+	"runtime/debug"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
 )
 
 // Main TxWrap data-structure.  Wraps the sqlx.Tx interface.  Once an error
@@ -18,15 +23,138 @@ import (
 //
 // Notes:
 // * Can get the raw sqlx.Tx or Err directly from the struct
-// * TxWrap is not thread-safe, must be synchronized externally to be used by multiple go-routines
-// * If you use sqlx.Rows, sqlx.Row, or sqlx.Stmt directly you'll have to implement and return your errors manually.
+// * TxWrap is not thread-safe, must be synchronized externally to be used by multiple go-routines (or use TxWrap.Concurrent)
+// * If you use sqlx.Rows, sqlx.Row, or sqlx.Stmt directly you'll have to implement and return your errors manually (or use Queryx, QueryRowx, or Preparex).
 type TxWrap struct {
 	Txx *sqlx.Tx
 	Err error
 
-	ctx context.Context
+	ctx          context.Context
+	ext          sqlx.ExtContext // if nil, Txx is used (DBWrap sets this to the *sqlx.DB)
+	db           txBeginner      // what the transaction was started on (nil for NewFromTx)
+	opts         *txOpts
+	sqlOpts      *sql.TxOptions
+	commitHooks  []func() error
+	beforeHooks  []func(tx *TxWrap) error
+	rbHooks      []func(err error)
+	savepointNum int
+	savepoints   []savepointMark
+	conn         *sqlx.Conn // set if the transaction was started with WithTxConn
+	stats        *TxStats   // nil unless stats are enabled
+	openRows     []*TxRows  // TxRows from Queryx that have not been closed
+	openStmts    []*TxStmt  // TxStmts from Preparex that have not been closed
+	errs         []error    // errors collected with WithCollectErrors (not including Err)
+	stmtTimeout  time.Duration
+	stmtCtxs     []stmtTimeoutCtx // see stmtTimeoutExt.rowsContext
+	auditTrail   []QueryInfo      // see WithAuditTrail
+	memoOn       bool             // see WithMemoize
+	memo         map[string]memoEntry
+	faults       *FaultConfig     // see WithFaultInjection
+	txCtx        context.Context  // cached Context() result, see updateCtx
+	dbMapper     *reflectx.Mapper // DBWrap only (the *sqlx.DB mapper), see TxWrap.mapper
 }
 
+// returns the sqlx handle used to run queries
+func (tx *TxWrap) queryer() sqlx.ExtContext {
+	if tx.ext != nil {
+		return tx.ext
+	}
+	return tx.Txx
+}
+
+// Returns true if tx.Err is set.  Also checks the context, if the context is done
+// (canceled or past its deadline) ctx.Err() is set on tx.Err before any more DB
+// calls are made, so cancellation stops the rest of the transaction immediately.
+// With WithCollectErrors, tx.Err is moved to the collected errors instead (unless
+// the context is done) and the DB call runs.
+func (tx *TxWrap) checkErr() bool {
+	if tx.Err != nil {
+		if !tx.isCollectErrors() || (tx.ctx != nil && tx.ctx.Err() != nil) {
+			return true
+		}
+		tx.errs = append(tx.errs, tx.Err)
+		tx.Err = nil
+	}
+	if tx.ctx != nil {
+		if err := tx.ctx.Err(); err != nil {
+			tx.Err = err
+			return true
+		}
+	}
+	return false
+}
+
+// Core query methods shared by TxWrap (in a transaction) and DBWrap (no
+// transaction, see NewDBWrap and WithDB).  Repository functions that take a
+// Querier can be called from inside WithTx or standalone:
+//
+//	func GetUser(q txwrap.Querier, id int64) (*User, error) {
+//	    var user User
+//	    if !q.Get(&user, `SELECT * FROM users WHERE id = $1`, id) {
+//	        return nil, nil
+//	    }
+//	    ...
+//	}
+//
+// Errors are handled the same way for both (the first error is kept and later calls
+// are skipped).  Use Tx for the full set of typed getters.
+type Querier interface {
+	Context() context.Context
+	Exec(query string, args ...interface{}) sql.Result
+	NamedExec(query string, arg interface{}) sql.Result
+	ExecAffected(query string, args ...interface{}) int64
+	Exists(query string, args ...interface{}) bool
+	NamedExists(query string, arg interface{}) bool
+	Get(dest interface{}, query string, args ...interface{}) bool
+	GetRequired(dest interface{}, query string, args ...interface{}) bool
+	Select(dest interface{}, query string, args ...interface{})
+	NamedGet(dest interface{}, query string, arg interface{}) bool
+	NamedSelect(dest interface{}, query string, arg interface{})
+	SetErr(err error)
+}
+
+// Query surface of TxWrap.  Repository code can accept a Tx instead of a
+// concrete *TxWrap so it can be exercised with a fake implementation in tests.
+// Implemented by TxWrap and DBWrap.
+type Tx interface {
+	Querier
+	ExecMany(statements []string)
+	ExecInsertId(query string, args ...interface{}) int64
+	ExecExpect(expected int64, query string, args ...interface{}) bool
+	GetString(query string, args ...interface{}) string
+	GetStringOk(query string, args ...interface{}) (string, bool)
+	GetFloat64(query string, args ...interface{}) float64
+	GetFloat64Ok(query string, args ...interface{}) (float64, bool)
+	GetByteArr(query string, args ...interface{}) []byte
+	GetBytesOk(query string, args ...interface{}) ([]byte, bool)
+	GetBool(query string, args ...interface{}) bool
+	GetBoolOk(query string, args ...interface{}) (bool, bool)
+	GetTime(query string, args ...interface{}) time.Time
+	GetNullTime(query string, args ...interface{}) (time.Time, bool)
+	GetUUID(query string, args ...interface{}) string
+	GetUUIDOk(query string, args ...interface{}) (string, bool)
+	SelectStrings(query string, args ...interface{}) []string
+	SelectInt64s(query string, args ...interface{}) []int64
+	SelectInts(query string, args ...interface{}) []int
+	SelectBools(query string, args ...interface{}) []bool
+	SelectTimes(query string, args ...interface{}) []time.Time
+	GetInt(query string, args ...interface{}) int
+	GetIntOk(query string, args ...interface{}) (int, bool)
+	GetInt64(query string, args ...interface{}) int64
+	GetInt64Ok(query string, args ...interface{}) (int64, bool)
+	GetCount(query string, args ...interface{}) int64
+	GetIn(dest interface{}, query string, args ...interface{}) bool
+	SelectIn(dest interface{}, query string, args ...interface{})
+	ExecIn(query string, args ...interface{}) sql.Result
+	SelectMaps(query string, args ...interface{}) []map[string]interface{}
+	GetMap(query string, args ...interface{}) map[string]interface{}
+	GetMapOk(query string, args ...interface{}) (map[string]interface{}, bool)
+	SelectOrderedMaps(query string, args ...interface{}) ([]string, []map[string]interface{})
+	Run(fn func() error)
+}
+
+var _ Tx = (*TxWrap)(nil)
+
 // context-key
 type txWrapKey struct{}
 
@@ -36,7 +164,20 @@ func IsTxWrapContext(ctx context.Context) bool {
 	return ctxVal != nil
 }
 
-func WithTxRtn[RT any](ctx context.Context, db *sqlx.DB, fn func(tx *TxWrap) (RT, error)) (RT, error) {
+// Returns the TxWrap running in the given Context (see TxWrap.Context), so helpers
+// that only receive a Context can use the active transaction.  Returns (nil, false)
+// if the Context is not running a TxWrap transaction.  Prefer WithTx when the helper
+// may also be called outside of a transaction, it reuses the active transaction
+// (or starts a new one).
+func TxFromContext(ctx context.Context) (*TxWrap, bool) {
+	txWrap, ok := ctx.Value(txWrapKey{}).(*TxWrap)
+	if !ok || txWrap == nil {
+		return nil, false
+	}
+	return txWrap, true
+}
+
+func WithTxRtn[RT any](ctx context.Context, db *sqlx.DB, fn func(tx *TxWrap) (RT, error), opts ...TxOption) (RT, error) {
 	var rtn RT
 	txErr := WithTx(ctx, db, func(tx *TxWrap) error {
 		temp, err := fn(tx)
@@ -45,91 +186,654 @@ func WithTxRtn[RT any](ctx context.Context, db *sqlx.DB, fn func(tx *TxWrap) (RT
 		}
 		rtn = temp
 		return nil
-	})
+	}, opts...)
 	return rtn, txErr
 }
 
+// Same as WithTxRtn, but returns two values from 'fn'.
+func WithTxRtn2[RT1 any, RT2 any](ctx context.Context, db *sqlx.DB, fn func(tx *TxWrap) (RT1, RT2, error), opts ...TxOption) (RT1, RT2, error) {
+	var rtn1 RT1
+	var rtn2 RT2
+	txErr := WithTx(ctx, db, func(tx *TxWrap) error {
+		temp1, temp2, err := fn(tx)
+		if err != nil {
+			return err
+		}
+		rtn1 = temp1
+		rtn2 = temp2
+		return nil
+	}, opts...)
+	return rtn1, rtn2, txErr
+}
+
+// Same as WithTxRtn, but returns three values from 'fn'.
+func WithTxRtn3[RT1 any, RT2 any, RT3 any](ctx context.Context, db *sqlx.DB, fn func(tx *TxWrap) (RT1, RT2, RT3, error), opts ...TxOption) (RT1, RT2, RT3, error) {
+	var rtn1 RT1
+	var rtn2 RT2
+	var rtn3 RT3
+	txErr := WithTx(ctx, db, func(tx *TxWrap) error {
+		temp1, temp2, temp3, err := fn(tx)
+		if err != nil {
+			return err
+		}
+		rtn1 = temp1
+		rtn2 = temp2
+		rtn3 = temp3
+		return nil
+	}, opts...)
+	return rtn1, rtn2, rtn3, txErr
+}
+
 // Main transaction wrapper. If any database call fails, or an error is returned from
 // 'fn' then the transation will be rolled back and the first error will be returned.
-// Otherwise the transaction will be committed and WithTx will return nil.
+// Otherwise the transaction will be committed and WithTx will return nil.  If the
+// Rollback itself fails, the rollback error is joined (errors.Join) with the original
+// error.  If 'fn' panics, the transaction is rolled back and the panic is re-raised
+// (if that Rollback fails, the re-raised value is an error wrapping the rollback error),
+// see RecoverPanics to return the panic as an error instead.
+// If 'ctx' is canceled (or its deadline passes) while 'fn' is running, the next TxWrap
+// call sets ctx.Err() as the error, so the rest of 'fn' is skipped.
 //
 // Note that WithTx *can* be nested.  If there is already an error WithTx will immediately
 // return that error.  Otherwise it will use the existing outer TxWrap object.  Note that
 // this will *not* run a nested DB transation.  Begin and Commit/Rollback will only
-// be called once for the *outer* transaction.
-func WithTx(ctx context.Context, db *sqlx.DB, fn func(tx *TxWrap) error) (rtnErr error) {
-	var txWrap *TxWrap
+// be called once for the *outer* transaction.  If the nested call passes a different
+// DB than the outer transaction, ErrNestedTxDB is returned (and the outer transaction
+// will be rolled back), unless WithIndependentNestedTx is set.
+//
+// Options (see TxOption) only apply when WithTx begins a new transaction.
+func WithTx(ctx context.Context, db *sqlx.DB, fn func(tx *TxWrap) error, opts ...TxOption) error {
+	if db == nil {
+		return withTx(ctx, nil, nil, fn, opts)
+	}
+	return withTx(ctx, db, nil, fn, opts)
+}
+
+// Same as WithTx, but passes 'sqlOpts' (isolation level, read-only) to BeginTxx.
+// If ctx is already running a TxWrap transaction the outer transaction is reused.
+// In that case, if 'sqlOpts' asks for guarantees the outer transaction cannot provide
+// (read-write inside a read-only transaction, or a stronger isolation level than
+// the outer transaction's explicit level) an error wrapping ErrNestedTxOptions is
+// returned and the outer transaction will be rolled back.
+func WithTxOptions(ctx context.Context, db *sqlx.DB, sqlOpts *sql.TxOptions, fn func(tx *TxWrap) error, opts ...TxOption) error {
+	if db == nil {
+		return withTx(ctx, nil, sqlOpts, fn, opts)
+	}
+	return withTx(ctx, db, sqlOpts, fn, opts)
+}
+
+// Runs WithTxOptions with sql.LevelSerializable
+func WithTxSerializable(ctx context.Context, db *sqlx.DB, fn func(tx *TxWrap) error, opts ...TxOption) error {
+	return WithTxOptions(ctx, db, &sql.TxOptions{Isolation: sql.LevelSerializable}, fn, opts...)
+}
+
+// Runs WithTxOptions with sql.LevelReadCommitted
+func WithTxReadCommitted(ctx context.Context, db *sqlx.DB, fn func(tx *TxWrap) error, opts ...TxOption) error {
+	return WithTxOptions(ctx, db, &sql.TxOptions{Isolation: sql.LevelReadCommitted}, fn, opts...)
+}
+
+// Runs WithTxOptions with ReadOnly set (default isolation level).  Exec calls (and
+// the helpers built on Exec) fail with ErrReadOnlyTx without being sent to the database.
+func WithTxReadOnly(ctx context.Context, db *sqlx.DB, fn func(tx *TxWrap) error, opts ...TxOption) error {
+	return WithTxOptions(ctx, db, &sql.TxOptions{ReadOnly: true}, fn, opts...)
+}
+
+// Runs a read-only transaction on a read replica.  If the transaction cannot be
+// started on 'replica' (e.g. the replica is down) or 'replica' is nil, it is started
+// on 'primary' instead (still read-only).  Retries (WithRetry) also use the replica
+// first.  Like WithTx, if ctx already has a TxWrap (e.g. inside of a write transaction
+// on the primary) the existing transaction is reused, so reads see the
+// transaction's own writes.
+func WithReadTx(ctx context.Context, primary *sqlx.DB, replica *sqlx.DB, fn func(tx *TxWrap) error, opts ...TxOption) error {
+	sqlOpts := &sql.TxOptions{ReadOnly: true}
+	if primary == nil {
+		return withTx(ctx, nil, sqlOpts, fn, opts)
+	}
+	if replica == nil {
+		return withTx(ctx, primary, sqlOpts, fn, opts)
+	}
+	return withTx(ctx, replicaBeginner{primary: primary, replica: replica}, sqlOpts, fn, opts)
+}
+
+// begins transactions on the replica, falling back to the primary
+type replicaBeginner struct {
+	primary *sqlx.DB
+	replica *sqlx.DB
+}
+
+func (b replicaBeginner) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
+	tx, err := b.replica.BeginTxx(ctx, opts)
+	if err == nil || ctx.Err() != nil {
+		return tx, err
+	}
+	return b.primary.BeginTxx(ctx, opts)
+}
+
+// Same as WithTx, but begins the transaction on a specific connection (conn.BeginTxx)
+// rather than on the pool.  Use this when the transaction must run on a pinned
+// connection (e.g. session PRAGMAs or advisory locks).  Nesting works identically
+// to WithTx, an existing outer TxWrap in ctx will be reused.  Inside the transaction
+// tx.Conn() returns 'conn'.  Use WithConn to check out a connection from a *sqlx.DB
+// (for a *sql.Conn, check it out with *sqlx.DB.Connx instead so sqlx knows the driver).
+func WithTxConn(ctx context.Context, conn *sqlx.Conn, fn func(tx *TxWrap) error, opts ...TxOption) error {
+	if conn == nil {
+		return withTx(ctx, nil, nil, fn, opts)
+	}
+	return withTx(ctx, conn, nil, fn, opts)
+}
+
+// Same as WithTx, but takes a plain database/sql *sql.DB so callers that do not
+// use sqlx can still use TxWrap.  'driverName' is the name the DB was opened with
+// (e.g. "postgres", "sqlite3") and is used to determine the bindvar type for
+// the Named* and *In methods.  The underlying *sql.Tx is available as tx.Txx.Tx.
+func WithSqlTx(ctx context.Context, db *sql.DB, driverName string, fn func(tx *TxWrap) error, opts ...TxOption) error {
+	if db == nil {
+		return withTx(ctx, nil, nil, fn, opts)
+	}
+	return withTx(ctx, sqlx.NewDb(db, driverName), nil, fn, opts)
+}
+
+// Returned (wrapped) from WithTxTimeout when the transaction deadline is exceeded.
+// The error also wraps context.DeadlineExceeded.
+var ErrTxTimeout = errors.New("transaction timeout")
+
+// Same as WithTx, but bounds the entire transaction to duration 'd'.  A child context
+// (context.WithTimeout) is used to begin the transaction and for all queries, once the
+// deadline passes the context is cancelled and the transaction is rolled back.  The
+// rollback happens as soon as the deadline passes (database/sql rolls back a transaction
+// when its context is done), even if 'fn' is not making DB calls, so a slow 'fn' does not
+// hold locks past the deadline.  If the transaction fails because the deadline was
+// exceeded the returned error wraps ErrTxTimeout and context.DeadlineExceeded.
+//
+// If ctx is already running a TxWrap transaction the outer transaction is reused and
+// the timeout is not applied.
+func WithTxTimeout(ctx context.Context, db *sqlx.DB, d time.Duration, fn func(tx *TxWrap) error, opts ...TxOption) error {
+	if IsTxWrapContext(ctx) {
+		return WithTx(ctx, db, fn, opts...)
+	}
+	timeoutCtx, cancelFn := context.WithTimeout(ctx, d)
+	defer cancelFn()
+	err := WithTx(timeoutCtx, db, fn, opts...)
+	if err != nil && ctx.Err() == nil && timeoutCtx.Err() == context.DeadlineExceeded {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%w, deadline of %v exceeded: %w", ErrTxTimeout, d, err)
+		}
+		return fmt.Errorf("%w, deadline of %v exceeded (%w): %w", ErrTxTimeout, d, context.DeadlineExceeded, err)
+	}
+	return err
+}
+
+// Wraps the error returned from WithTx when Commit fails.  Unlike errors from 'fn'
+// (where the transaction was rolled back), the outcome of a failed commit can be
+// ambiguous: e.g. if the connection dropped, the server may have committed.
+var ErrCommitFailed = errors.New("commit failed")
+
+// Returned (wrapped) when a nested WithTxOptions call is not compatible with
+// the options of the outer transaction.
+var ErrNestedTxOptions = errors.New("nested transaction options are incompatible with outer transaction")
+
+// outer isolation of sql.LevelDefault is unknown, so it is not checked
+func checkNestedTxOptions(outer *sql.TxOptions, nested *sql.TxOptions) error {
+	if nested == nil {
+		return nil
+	}
+	if outer == nil {
+		outer = &sql.TxOptions{}
+	}
+	if outer.ReadOnly && !nested.ReadOnly {
+		return fmt.Errorf("%w: read-write transaction requested inside read-only transaction", ErrNestedTxOptions)
+	}
+	if outer.Isolation != sql.LevelDefault && nested.Isolation > outer.Isolation {
+		return fmt.Errorf("%w: isolation %v requested inside %v transaction", ErrNestedTxOptions, nested.Isolation, outer.Isolation)
+	}
+	return nil
+}
+
+// Returned (wrapped) when a nested WithTx call passes a different DB than the one
+// the outer transaction was started on (its queries would otherwise silently run on
+// the outer transaction's database).  See WithIndependentNestedTx.
+var ErrNestedTxDB = errors.New("nested transaction requested on a different DB than outer transaction")
+
+// returns the *sql.DBs that a txBeginner can start a transaction on (nil if not
+// known, e.g. for a *sqlx.Conn)
+func beginnerDBs(db txBeginner) []*sql.DB {
+	switch b := db.(type) {
+	case *sqlx.DB:
+		if b != nil {
+			return []*sql.DB{b.DB}
+		}
+	case replicaBeginner:
+		return []*sql.DB{b.primary.DB, b.replica.DB}
+	}
+	return nil
+}
+
+// returns true if 'nested' may be the same database as 'outer'.  the *sql.DB is
+// compared (not the *sqlx.DB) since WithSqlTx creates a new *sqlx.DB for each call.
+// if either side is unknown (nil, or a *sqlx.Conn) the outer transaction is reused.
+func isSameTxDB(outer txBeginner, nested txBeginner) bool {
+	outerDBs := beginnerDBs(outer)
+	nestedDBs := beginnerDBs(nested)
+	if len(outerDBs) == 0 || len(nestedDBs) == 0 {
+		return true
+	}
+	for _, outerDB := range outerDBs {
+		for _, nestedDB := range nestedDBs {
+			if outerDB == nestedDB {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// implemented by *sqlx.DB and *sqlx.Conn (and replicaBeginner)
+type txBeginner interface {
+	BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
+}
+
+func withTx(ctx context.Context, db txBeginner, sqlOpts *sql.TxOptions, fn func(tx *TxWrap) error, opts []TxOption) error {
 	ctxVal := ctx.Value(txWrapKey{})
 	if ctxVal != nil {
-		txWrap = ctxVal.(*TxWrap)
-		if txWrap.Err != nil {
-			return txWrap.Err
+		outerTx := ctxVal.(*TxWrap)
+		if isSameTxDB(outerTx.db, db) {
+			return withNestedTx(ctx, outerTx, sqlOpts, fn)
+		}
+		if !makeTxOpts(opts).independentNestedTx {
+			outerTx.SetErr(ErrNestedTxDB)
+			return outerTx.Err
 		}
+		// hide the outer transaction, so 'fn' (and WithTx calls inside of it) use the new one
+		ctx = context.WithValue(ctx, txWrapKey{}, nil)
 	}
-	if txWrap == nil {
-		if db == nil {
-			return fmt.Errorf("invalid nil DB passed to WithTxDB")
+	if db == nil {
+		return fmt.Errorf("invalid nil DB passed to WithTxDB")
+	}
+	txOpts := makeTxOpts(opts)
+	sqlOpts = txOpts.makeSqlOpts(sqlOpts)
+	ctx, endTxHooks := txOpts.startTxHooks(ctx)
+	txInfo := TxInfo{}
+	var stats *TxStats
+	if len(txOpts.statsFns) > 0 {
+		stats = &TxStats{}
+	}
+	clock := txOpts.getClock()
+	startTs := clock.Now()
+	var txWrap *TxWrap
+	defer func() {
+		txInfo.Duration = clock.Now().Sub(startTs)
+		if stats != nil {
+			stats.Duration = txInfo.Duration
+			stats.Retries = max(0, txInfo.Attempts-1)
+			txOpts.reportStats(ctx, stats)
 		}
-		tx, beginErr := db.BeginTxx(ctx, nil)
-		if beginErr != nil {
-			return beginErr
+		txOpts.reportAuditTrail(ctx, txWrap)
+		if p := recover(); p != nil {
+			txInfo.Err = fmt.Errorf("panic in transaction: %v", p)
+			endTxHooks(txInfo)
+			panic(p)
 		}
-		txWrap = &TxWrap{Txx: tx, ctx: ctx}
-		defer func() {
-			if p := recover(); p != nil {
-				txWrap.Txx.Rollback()
-				panic(p)
+		endTxHooks(txInfo)
+	}()
+	var attemptErr error
+	attemptFn := func() error {
+		txInfo.Attempts++
+		if txInfo.Attempts > 1 {
+			txOpts.logRetry(ctx, txInfo.Attempts, attemptErr)
+		}
+		txWrap, attemptErr = runTx(ctx, db, sqlOpts, fn, txOpts, stats)
+		return attemptErr
+	}
+	var err error
+	if txOpts.retry != nil {
+		err = txOpts.retry.run(ctx, txOpts.getClock(), attemptFn)
+	} else {
+		err = attemptFn()
+	}
+	if isForcedRollbackErr(err) {
+		err = nil
+	} else if err == nil {
+		txInfo.Committed = true
+		err = txWrap.runCommitHooks()
+	}
+	err = txOpts.wrapErr(err)
+	txInfo.Err = err
+	return err
+}
+
+func withNestedTx(ctx context.Context, txWrap *TxWrap, sqlOpts *sql.TxOptions, fn func(tx *TxWrap) error) error {
+	if txWrap.Err != nil {
+		return txWrap.Err
+	}
+	optsErr := checkNestedTxOptions(txWrap.sqlOpts, sqlOpts)
+	if optsErr != nil {
+		txWrap.SetErr(optsErr)
+		return txWrap.Err
+	}
+	// use the nested ctx (which chains from the outer tx context) while the
+	// nested fn runs so queries and hooks see any values added to it
+	outerCtx, outerTxCtx := txWrap.ctx, txWrap.txCtx
+	txWrap.updateCtx(ctx)
+	defer func() {
+		txWrap.updateCtx(outerCtx)
+		txWrap.txCtx = outerTxCtx
+	}()
+	fnErr := fn(txWrap)
+	txWrap.joinCollectedErrs()
+	if txWrap.Err == nil && fnErr != nil {
+		txWrap.Err = fnErr
+	}
+	return txWrap.Err
+}
+
+// runs a single attempt of the outer transaction (begin, fn, commit/rollback)
+func runTx(ctx context.Context, db txBeginner, sqlOpts *sql.TxOptions, fn func(tx *TxWrap) error, txOpts *txOpts, stats *TxStats) (txWrap *TxWrap, rtnErr error) {
+	unlockWrites, lockErr := txOpts.lockWrites(ctx, db, sqlOpts)
+	if lockErr != nil {
+		return nil, lockErr
+	}
+	defer unlockWrites()
+	tx, beginErr := db.BeginTxx(ctx, sqlOpts)
+	if beginErr != nil {
+		return nil, beginErr
+	}
+	txWrap = &TxWrap{Txx: tx, ctx: ctx, db: db, opts: txOpts, sqlOpts: sqlOpts, stats: stats}
+	if conn, ok := db.(*sqlx.Conn); ok {
+		txWrap.conn = conn
+	}
+	closeExt := txWrap.setupTxExt()
+	defer closeExt()
+	defer func() {
+		txWrap.closeOpenRows()
+		txWrap.closeOpenStmts()
+		txWrap.cancelStmtTimeouts()
+		if p := recover(); p != nil {
+			if txOpts.recoverPanics {
+				rtnErr = &PanicError{Value: p, Stack: debug.Stack()}
+				txWrap.SetErr(rtnErr)
+				rbErr := txWrap.rollback()
+				if rbErr != nil {
+					rtnErr = errors.Join(rtnErr, rbErr)
+				}
+				txWrap.runRollbackHooks(rtnErr)
+				return
 			}
-			if rtnErr != nil {
-				txWrap.Txx.Rollback()
-			} else {
-				rtnErr = txWrap.Txx.Commit()
+			rbErr := txWrap.rollback()
+			if rbErr != nil {
+				panicErr := fmt.Errorf("panic in transaction: %v (%w)", p, rbErr)
+				txWrap.runRollbackHooks(panicErr)
+				panic(panicErr)
 			}
-		}()
-	}
+			txWrap.runRollbackHooks(fmt.Errorf("panic in transaction: %v", p))
+			panic(p)
+		}
+		if rtnErr == nil && txOpts.dryRun == nil {
+			rtnErr = txWrap.writeAuditTrail()
+		}
+		if rtnErr != nil {
+			rbErr := txWrap.rollback()
+			if rbErr != nil {
+				rtnErr = errors.Join(rtnErr, rbErr)
+			}
+			txWrap.runRollbackHooks(rtnErr)
+			return
+		}
+		if forcedErr := txOpts.forcedRollbackErr(); forcedErr != nil {
+			rtnErr = txWrap.rollback()
+			if rtnErr == nil {
+				rtnErr = forcedErr
+			}
+			txWrap.runRollbackHooks(forcedErr)
+			return
+		}
+		commitTs := txWrap.now()
+		rtnErr = txWrap.commitFault()
+		if rtnErr == nil {
+			rtnErr = txWrap.Txx.Commit()
+		}
+		if stats != nil {
+			stats.CommitDuration += txWrap.since(commitTs)
+		}
+		if rtnErr != nil {
+			rtnErr = fmt.Errorf("%w: %w", ErrCommitFailed, rtnErr)
+			txWrap.runRollbackHooks(rtnErr)
+		}
+	}()
 	fnErr := fn(txWrap)
+	txWrap.joinCollectedErrs()
 	if txWrap.Err == nil && fnErr != nil {
 		txWrap.Err = fnErr
 	}
-	if txWrap.Err != nil {
-		return txWrap.Err
+	txWrap.runBeforeCommitHooks()
+	return txWrap, txWrap.Err
+}
+
+// wraps the query handle for a new transaction (the statement cache and the query
+// options).  used by runTx and runMultiTx.  the order matters, each wrapper wraps the
+// ones set up before it.  returns a function to call after the transaction ends.
+func (tx *TxWrap) setupTxExt() func() {
+	closeFn := func() {}
+	if tx.opts.stmtCache {
+		cache := makeStmtCache(tx.Txx)
+		tx.ext = cache
+		closeFn = cache.close
 	}
-	return nil
+	tx.setupFaultInjection()
+	tx.setupDryRun()
+	tx.setupExplain()
+	tx.setupSQLCommenter()
+	tx.setupAutoRebind()
+	tx.setupQueryRewrite()
+	tx.setupStatementTimeout()
+	tx.setupMemoize()
+	tx.setupReadOnly()
+	return closeFn
+}
+
+// Returns a non-nil error (wrapping the Rollback error) if Rollback fails.
+// sql.ErrTxDone is ignored since it means the transaction was already
+// rolled back (e.g. the context was cancelled).
+func (tx *TxWrap) rollback() error {
+	err := tx.Txx.Rollback()
+	if err == nil || errors.Is(err, sql.ErrTxDone) {
+		return nil
+	}
+	return fmt.Errorf("rollback failed: %w", err)
+}
+
+// Wraps an already-begun *sqlx.Tx (e.g. one handed to you by another library) so
+// the TxWrap helpers and error handling can be used with it.  TxWrap does *not*
+// own the transaction: it never calls Commit or Rollback, the caller must check
+// tx.Err and finish the transaction.  Query options (e.g. WithQueryHook) apply,
+// but TxHooks, retries, and OnCommit/OnRollback callbacks are never run.
+func NewFromTx(ctx context.Context, tx *sqlx.Tx, opts ...TxOption) *TxWrap {
+	txWrap := &TxWrap{Txx: tx, ctx: ctx, opts: makeTxOpts(opts)}
+	txWrap.setupSQLCommenter()
+	txWrap.setupAutoRebind()
+	txWrap.setupQueryRewrite()
+	txWrap.setupStatementTimeout()
+	return txWrap
+}
+
+// Runs 'fn' with a TxWrap around an existing *sqlx.Tx (see NewFromTx) and returns
+// the first error (tx.Err or the error returned from 'fn').  The caller remains
+// responsible for committing or rolling back 'tx'.
+func RunInExistingTx(ctx context.Context, tx *sqlx.Tx, fn func(tx *TxWrap) error, opts ...TxOption) error {
+	if tx == nil {
+		return fmt.Errorf("invalid nil Tx passed to RunInExistingTx")
+	}
+	txWrap := NewFromTx(ctx, tx, opts...)
+	fnErr := fn(txWrap)
+	txWrap.joinCollectedErrs()
+	if txWrap.Err == nil && fnErr != nil {
+		txWrap.Err = fnErr
+	}
+	return txWrap.Err
 }
 
 // Returns the TxWrap Context (with the txWrapKey).
-// Must use this Context for nested calls to TxWrap
+// Must use this Context for nested calls to TxWrap.  The returned Context always
+// chains from the Context passed to WithTx, so request-scoped values set by the
+// caller are visible to nested calls and to QueryHooks.
 func (tx *TxWrap) Context() context.Context {
-	return context.WithValue(tx.ctx, txWrapKey{}, tx)
+	// cached, since Context is called for every nested call (cleared by updateCtx)
+	if tx.txCtx == nil {
+		if ctxTx, ok := tx.ctx.Value(txWrapKey{}).(*TxWrap); ok && ctxTx == tx {
+			// nested WithTx with a ctx derived from tx.Context(), already carries tx
+			tx.txCtx = tx.ctx
+		} else {
+			tx.txCtx = context.WithValue(tx.ctx, txWrapKey{}, tx)
+		}
+	}
+	return tx.txCtx
+}
+
+// changes tx.ctx (after the TxWrap is created), must be used instead of setting tx.ctx
+func (tx *TxWrap) updateCtx(ctx context.Context) {
+	tx.ctx = ctx
+	tx.txCtx = nil
+}
+
+// sql.Result returned by Exec/NamedExec when the TxWrap has an error.
+// LastInsertId and RowsAffected return the TxWrap error.
+type errResult struct {
+	err error
+}
+
+func (r errResult) LastInsertId() (int64, error) {
+	return 0, r.err
+}
+
+func (r errResult) RowsAffected() (int64, error) {
+	return 0, r.err
 }
 
+// Never returns nil.  If there is an error, the returned sql.Result will return
+// the error from LastInsertId and RowsAffected.
 func (tx *TxWrap) NamedExec(query string, arg interface{}) sql.Result {
-	if tx.Err != nil {
-		return nil
+	if tx.checkErr() {
+		return errResult{tx.Err}
 	}
-	result, err := tx.Txx.NamedExecContext(tx.ctx, query, arg)
+	startTs := tx.now()
+	result, err := sqlx.NamedExecContext(tx.ctx, tx.queryer(), query, arg)
+	tx.observeExec("NamedExec", query, []interface{}{arg}, startTs, result, err)
 	if err != nil {
-		tx.Err = err
+		tx.setQueryErr("NamedExec", query, []interface{}{arg}, err)
+		return errResult{err}
 	}
 	return result
 }
 
+// Never returns nil.  If there is an error, the returned sql.Result will return
+// the error from LastInsertId and RowsAffected.
 func (tx *TxWrap) Exec(query string, args ...interface{}) sql.Result {
-	if tx.Err != nil {
-		return nil
+	if tx.checkErr() {
+		return errResult{tx.Err}
 	}
-	result, err := tx.Txx.ExecContext(tx.ctx, query, args...)
+	startTs := tx.now()
+	result, err := tx.queryer().ExecContext(tx.ctx, query, args...)
+	tx.observeExec("Exec", query, args, startTs, result, err)
 	if err != nil {
-		tx.Err = err
+		tx.setQueryErr("Exec", query, args, err)
+		return errResult{err}
 	}
 	return result
 }
 
+// Runs Exec and returns the number of rows affected.  Returns 0 if there is an
+// error (including an error from RowsAffected, which is set on tx.Err).
+func (tx *TxWrap) ExecAffected(query string, args ...interface{}) int64 {
+	result := tx.Exec(query, args...)
+	if tx.Err != nil {
+		return 0
+	}
+	numRows, err := result.RowsAffected()
+	if err != nil {
+		tx.setQueryErr("ExecAffected", query, args, err)
+		return 0
+	}
+	return numRows
+}
+
+// Runs Exec and returns LastInsertId (the auto-increment id for MySQL/SQLite
+// inserts).  Returns 0 if there is an error (including an error from LastInsertId,
+// e.g. for drivers that do not support it, which is set on tx.Err).
+func (tx *TxWrap) ExecInsertId(query string, args ...interface{}) int64 {
+	result := tx.Exec(query, args...)
+	if tx.Err != nil {
+		return 0
+	}
+	insertId, err := result.LastInsertId()
+	if err != nil {
+		tx.setQueryErr("ExecInsertId", query, args, err)
+		return 0
+	}
+	return insertId
+}
+
+// Runs Exec and checks that exactly 'expected' rows were affected.  If not, tx.Err
+// is set to an *AffectedRowsError and false is returned.
+func (tx *TxWrap) ExecExpect(expected int64, query string, args ...interface{}) bool {
+	numRows := tx.ExecAffected(query, args...)
+	if tx.Err != nil {
+		return false
+	}
+	if numRows != expected {
+		tx.Err = &AffectedRowsError{Query: query, Expected: expected, Actual: numRows}
+		return false
+	}
+	return true
+}
+
+// Same as ExecExpect, for DELETE statements
+func (tx *TxWrap) DeleteExpect(expected int64, query string, args ...interface{}) bool {
+	return tx.ExecExpect(expected, query, args...)
+}
+
+// Same as ExecExpect, for UPDATE statements
+func (tx *TxWrap) UpdateExpect(expected int64, query string, args ...interface{}) bool {
+	return tx.ExecExpect(expected, query, args...)
+}
+
+// Runs each statement in order (using Exec), stopping at the first failure.
+// Useful for migrations since many drivers do not allow multiple statements
+// in a single Exec.
+func (tx *TxWrap) ExecMany(statements []string) {
+	for _, stmt := range statements {
+		if tx.Err != nil {
+			return
+		}
+		tx.Exec(stmt)
+	}
+}
+
 // Returns false if there is an error or the query returns sql.ErrNoRows.
-// Otherwise if there is at least 1 matching row, returns true.
+// Otherwise if there is at least 1 matching row, returns true.  A missing row
+// is never an error for Exists (even with WithStrictNoRows).
+//
+// If the WithSelectExists option is set, the query is run as SELECT EXISTS(<query>)
+// so the database can stop at the first matching row.
 func (tx *TxWrap) Exists(query string, args ...interface{}) bool {
+	if tx.opts != nil && tx.opts.selectExists {
+		var exists bool
+		tx.get(&exists, "SELECT EXISTS("+query+")", args, false)
+		return exists
+	}
 	var dest interface{}
-	return tx.Get(&dest, query, args...)
+	return tx.get(&dest, query, args, false)
+}
+
+// Same as Exists, but takes named parameters (struct or map) like NamedExec.
+func (tx *TxWrap) NamedExists(query string, arg interface{}) bool {
+	if tx.checkErr() {
+		return false
+	}
+	boundQuery, args, err := tx.queryer().BindNamed(query, arg)
+	if err != nil {
+		tx.setQueryErr("NamedExists", query, []interface{}{arg}, err)
+		return false
+	}
+	return tx.Exists(boundQuery, args...)
 }
 
 func (tx *TxWrap) GetString(query string, args ...interface{}) string {
@@ -141,6 +845,17 @@ func (tx *TxWrap) GetString(query string, args ...interface{}) string {
 	return *rtnStr
 }
 
+// Returns the value and true if a row was found with a non-NULL value.
+// Returns ("", false) for NULL, no rows, or an error.
+func (tx *TxWrap) GetStringOk(query string, args ...interface{}) (string, bool) {
+	var rtnStr *string
+	tx.Get(&rtnStr, query, args...)
+	if rtnStr == nil {
+		return "", false
+	}
+	return *rtnStr, true
+}
+
 func (tx *TxWrap) GetFloat64(query string, args ...interface{}) float64 {
 	var rtnFloat *float64
 	tx.Get(&rtnFloat, query, args...)
@@ -150,6 +865,17 @@ func (tx *TxWrap) GetFloat64(query string, args ...interface{}) float64 {
 	return *rtnFloat
 }
 
+// Returns the value and true if a row was found with a non-NULL value.
+// Returns (0, false) for NULL, no rows, or an error.
+func (tx *TxWrap) GetFloat64Ok(query string, args ...interface{}) (float64, bool) {
+	var rtnFloat *float64
+	tx.Get(&rtnFloat, query, args...)
+	if rtnFloat == nil {
+		return 0, false
+	}
+	return *rtnFloat, true
+}
+
 func (tx *TxWrap) GetByteArr(query string, args ...interface{}) []byte {
 	var rtnByteArr *[]byte
 	tx.Get(&rtnByteArr, query, args...)
@@ -159,22 +885,212 @@ func (tx *TxWrap) GetByteArr(query string, args ...interface{}) []byte {
 	return *rtnByteArr
 }
 
+// Returns the raw bytes and whether a row was found.  A NULL column is a valid
+// found value (nil, true), an empty column returns a non-nil empty slice, and no
+// matching row (or an error) returns (nil, false).
+func (tx *TxWrap) GetBytesOk(query string, args ...interface{}) ([]byte, bool) {
+	var rtnBytes *[]byte
+	found := tx.Get(&rtnBytes, query, args...)
+	if !found {
+		return nil, false
+	}
+	if rtnBytes == nil {
+		return nil, true
+	}
+	if *rtnBytes == nil {
+		// drivers may return a nil slice for an empty (non-NULL) value
+		return []byte{}, true
+	}
+	return *rtnBytes, true
+}
+
 func (tx *TxWrap) GetBool(query string, args ...interface{}) bool {
 	var rtnBool bool
 	tx.Get(&rtnBool, query, args...)
 	return rtnBool
 }
 
-func GetGeneric[RT any](tx TxWrap, query string, args ...interface{}) RT {
+// Returns the value and true if a row was found with a non-NULL value.
+// Returns (false, false) for NULL, no rows, or an error.
+func (tx *TxWrap) GetBoolOk(query string, args ...interface{}) (bool, bool) {
+	var rtnBool *bool
+	tx.Get(&rtnBool, query, args...)
+	if rtnBool == nil {
+		return false, false
+	}
+	return *rtnBool, true
+}
+
+func (tx *TxWrap) GetTime(query string, args ...interface{}) time.Time {
+	rtn, _ := tx.GetNullTime(query, args...)
+	return rtn
+}
+
+// Returns the value and true if a row was found with a non-NULL value.
+// Returns (time.Time{}, false) for NULL, no rows, or an error.
+func (tx *TxWrap) GetNullTime(query string, args ...interface{}) (time.Time, bool) {
+	var rtnTime *time.Time
+	tx.Get(&rtnTime, query, args...)
+	if rtnTime == nil {
+		return time.Time{}, false
+	}
+	return *rtnTime, true
+}
+
+// Returns a UUID in canonical (lowercase, hyphenated) form.  The column can be a
+// native uuid type, a string (with or without hyphens), or 16 raw bytes.  If the
+// value is not a valid UUID, tx.Err is set.
+func (tx *TxWrap) GetUUID(query string, args ...interface{}) string {
+	rtn, _ := tx.GetUUIDOk(query, args...)
+	return rtn
+}
+
+// Returns the value and true if a row was found with a non-NULL value.
+// Returns ("", false) for NULL, no rows, or an error.
+func (tx *TxWrap) GetUUIDOk(query string, args ...interface{}) (string, bool) {
+	var rtnVal interface{}
+	tx.Get(&rtnVal, query, args...)
+	if tx.Err != nil || rtnVal == nil {
+		return "", false
+	}
+	uuidStr, err := formatUUID(rtnVal)
+	if err != nil {
+		tx.setQueryErr("GetUUID", query, args, fmt.Errorf("txwrap GetUUID, %w", err))
+		return "", false
+	}
+	return uuidStr, true
+}
+
+// Gets a single value (or struct) of type RT.  'tx' is the Tx interface (pass a
+// *TxWrap or *DBWrap), so errors are set on the caller's TxWrap and later calls are
+// skipped, same as the TxWrap methods.  Returns the zero value for no rows or an error.
+func GetGeneric[RT any](tx Tx, query string, args ...interface{}) RT {
 	var rtn RT
 	tx.Get(&rtn, query, args...)
 	return rtn
 }
 
+// Scans a single column into RT (RT may implement sql.Scanner).  Returns the zero
+// value and false for NULL, no rows, or an error, otherwise the value and true.
+// Only real errors (not sql.ErrNoRows) set tx.Err.
+func GetScalar[RT any](tx Tx, query string, args ...interface{}) (RT, bool) {
+	var rtn *RT
+	tx.Get(&rtn, query, args...)
+	if rtn == nil {
+		var zero RT
+		return zero, false
+	}
+	return *rtn, true
+}
+
+// Same as GetScalar, but RT can be any type Get accepts.  For scalar types (and
+// time.Time) returns false for NULL, no rows, or an error.  For structs (scanned by
+// db tags), pointer types, and sql.Scanner types, only no rows (or an error) returns
+// false, so use sql.Null[T] as RT to tell a NULL value apart from a missing row:
+//
+//	val, found := txwrap.GetOpt[sql.Null[int64]](tx, query)
+//	// found == false: no row, found && !val.Valid: NULL
+func GetOpt[RT any](tx Tx, query string, args ...interface{}) (RT, bool) {
+	rtnType := reflect.TypeOf((*RT)(nil)).Elem()
+	if rtnType.Kind() == reflect.Pointer || isStructScanType(rtnType) || reflect.PointerTo(rtnType).Implements(scannerType) {
+		var rtn RT
+		found := tx.Get(&rtn, query, args...)
+		return rtn, found
+	}
+	return GetScalar[RT](tx, query, args...)
+}
+
+// Selects rows into a []RT.  RT can be a scalar type (single column) or a struct
+// (columns are mapped using the struct's db tags, like sqlx.Select).
+func SelectGeneric[RT any](tx Tx, query string, args ...interface{}) []RT {
+	var rtn []RT
+	tx.Select(&rtn, query, args...)
+	return rtn
+}
+
+// Selects a single column into a []RT (e.g. a list of ids).  RT must be a scalar
+// type, time.Time, or a sql.Scanner (use a pointer or sql.Null[T] if the column
+// can be NULL).  The query must return exactly one column.
+func SelectColumn[RT any](tx Tx, query string, args ...interface{}) []RT {
+	var rtn []RT
+	tx.Select(&rtn, query, args...)
+	return rtn
+}
+
+// Same as GetGeneric, but the query runs with 'ctx' instead of the TxWrap context
+// (use this for a per-query deadline, or to stop when a request is canceled).  If
+// 'ctx' is already done, ctx.Err() is set on tx and the query does not run.
+func GetGenericContext[RT any](ctx context.Context, tx Tx, query string, args ...interface{}) RT {
+	var rtn RT
+	runWithContext(ctx, tx, func() {
+		tx.Get(&rtn, query, args...)
+	})
+	return rtn
+}
+
+// Same as SelectGeneric, but the query runs with 'ctx' (see GetGenericContext)
+func SelectGenericContext[RT any](ctx context.Context, tx Tx, query string, args ...interface{}) []RT {
+	var rtn []RT
+	runWithContext(ctx, tx, func() {
+		tx.Select(&rtn, query, args...)
+	})
+	return rtn
+}
+
+// Same as tx.Exists, but the query runs with 'ctx' (see GetGenericContext)
+func ExistsContext(ctx context.Context, tx Tx, query string, args ...interface{}) bool {
+	var rtn bool
+	runWithContext(ctx, tx, func() {
+		rtn = tx.Exists(query, args...)
+	})
+	return rtn
+}
+
+// implemented by TxWrap and DBWrap to temporarily run queries with a different context
+type contextSetter interface {
+	setContext(ctx context.Context) (restoreFn func())
+}
+
+func (tx *TxWrap) setContext(ctx context.Context) func() {
+	oldCtx := tx.ctx
+	tx.updateCtx(ctx)
+	return func() {
+		tx.updateCtx(oldCtx)
+	}
+}
+
+// runs 'fn' with 'ctx' set as the Tx's context.  Tx implementations that don't
+// support changing the context (e.g. test fakes) run 'fn' unchanged.
+func runWithContext(ctx context.Context, tx Tx, fn func()) {
+	if err := ctx.Err(); err != nil {
+		tx.SetErr(err)
+		return
+	}
+	if setter, ok := tx.(contextSetter); ok {
+		restoreFn := setter.setContext(ctx)
+		defer restoreFn()
+	}
+	fn()
+}
+
 func (tx *TxWrap) SelectStrings(query string, args ...interface{}) []string {
-	var rtnArr []string
-	tx.Select(&rtnArr, query, args...)
-	return rtnArr
+	return SelectColumn[string](tx, query, args...)
+}
+
+func (tx *TxWrap) SelectInt64s(query string, args ...interface{}) []int64 {
+	return SelectColumn[int64](tx, query, args...)
+}
+
+func (tx *TxWrap) SelectInts(query string, args ...interface{}) []int {
+	return SelectColumn[int](tx, query, args...)
+}
+
+func (tx *TxWrap) SelectBools(query string, args ...interface{}) []bool {
+	return SelectColumn[bool](tx, query, args...)
+}
+
+func (tx *TxWrap) SelectTimes(query string, args ...interface{}) []time.Time {
+	return SelectColumn[time.Time](tx, query, args...)
 }
 
 func (tx *TxWrap) GetInt(query string, args ...interface{}) int {
@@ -195,75 +1111,366 @@ func (tx *TxWrap) GetInt64(query string, args ...interface{}) int64 {
 	return *rtnInt
 }
 
+// Returns the value and true if a row was found with a non-NULL value.
+// Returns (0, false) for NULL, no rows, or an error.
+func (tx *TxWrap) GetIntOk(query string, args ...interface{}) (int, bool) {
+	var rtnInt *int
+	tx.Get(&rtnInt, query, args...)
+	if rtnInt == nil {
+		return 0, false
+	}
+	return *rtnInt, true
+}
+
+// Returns the value and true if a row was found with a non-NULL value.
+// Returns (0, false) for NULL, no rows, or an error.
+func (tx *TxWrap) GetInt64Ok(query string, args ...interface{}) (int64, bool) {
+	var rtnInt *int64
+	tx.Get(&rtnInt, query, args...)
+	if rtnInt == nil {
+		return 0, false
+	}
+	return *rtnInt, true
+}
+
+// For COUNT(*) style queries.  Unlike GetInt64, no rows and NULL are errors (set
+// on tx.Err), so a returned 0 is always a real count when tx.Err is nil.  Returns
+// 0 if there is an error.
+func (tx *TxWrap) GetCount(query string, args ...interface{}) int64 {
+	var rtnInt *int64
+	if !tx.GetRequired(&rtnInt, query, args...) {
+		return 0
+	}
+	if rtnInt == nil {
+		tx.SetErr(fmt.Errorf("GetCount query %q returned NULL", truncateStr(query, maxQueryErrorQueryLen)))
+		return 0
+	}
+	return *rtnInt
+}
+
 // If there is an error or sql.ErrNoRows will return false, otherwise true.
-// Note that sql.ErrNoRows will *not* error out the TxWrap.
+// Note that sql.ErrNoRows will *not* error out the TxWrap (unless the
+// WithStrictNoRows option is set, see GetRequired).
 func (tx *TxWrap) Get(dest interface{}, query string, args ...interface{}) bool {
-	if tx.Err != nil {
+	return tx.get(dest, query, args, tx.isStrictNoRows())
+}
+
+// Same as Get, but a missing row is an error.  If the query returns sql.ErrNoRows
+// tx.Err is set to an error wrapping sql.ErrNoRows and false is returned.
+func (tx *TxWrap) GetRequired(dest interface{}, query string, args ...interface{}) bool {
+	return tx.get(dest, query, args, true)
+}
+
+func (tx *TxWrap) get(dest interface{}, query string, args []interface{}, strictNoRows bool) bool {
+	if tx.checkErr() {
 		return false
 	}
-	err := tx.Txx.GetContext(tx.ctx, dest, query, args...)
+	memoKey := tx.memoKey("Get", dest, query, args)
+	if found, ok := tx.memoLookup(memoKey, dest); ok {
+		if !found && strictNoRows {
+			tx.setQueryErr("Get", query, args, noRowsError(query))
+		}
+		return found
+	}
+	startTs := tx.now()
+	err := tx.prepareRowStmt(query)
+	if err == nil {
+		err = sqlx.GetContext(tx.ctx, tx.queryer(), dest, query, args...)
+	}
+	tx.observeQuery("Get", query, args, startTs, err)
 	if err != nil && err == sql.ErrNoRows {
+		tx.memoStore(memoKey, dest, false)
+		if strictNoRows {
+			tx.setQueryErr("Get", query, args, noRowsError(query))
+		}
 		return false
 	}
 	if err != nil {
-		tx.Err = err
+		tx.setQueryErr("Get", query, args, err)
 		return false
 	}
+	tx.memoStore(memoKey, dest, true)
 	return true
 }
 
+func (tx *TxWrap) isStrictNoRows() bool {
+	return tx.opts != nil && tx.opts.strictNoRows
+}
+
+func noRowsError(query string) error {
+	return fmt.Errorf("no rows returned for required query %q: %w", query, sql.ErrNoRows)
+}
+
 func (tx *TxWrap) Select(dest interface{}, query string, args ...interface{}) {
-	if tx.Err != nil {
+	if tx.checkErr() {
+		return
+	}
+	memoKey := tx.memoKey("Select", dest, query, args)
+	if _, ok := tx.memoLookup(memoKey, dest); ok {
 		return
 	}
-	err := tx.Txx.SelectContext(tx.ctx, dest, query, args...)
+	startTs := tx.now()
+	err := sqlx.SelectContext(tx.ctx, tx.queryer(), dest, query, args...)
+	tx.observeQuery("Select", query, args, startTs, err)
 	if err != nil {
-		tx.Err = err
+		tx.setQueryErr("Select", query, args, err)
+		return
 	}
+	tx.memoStore(memoKey, dest, true)
 }
 
-func (tx *TxWrap) SelectMaps(query string, args ...interface{}) []map[string]interface{} {
-	if tx.Err != nil {
+// Same as Get, but takes named parameters (struct or map) like NamedExec.
+func (tx *TxWrap) NamedGet(dest interface{}, query string, arg interface{}) bool {
+	if tx.checkErr() {
+		return false
+	}
+	boundQuery, args, err := tx.queryer().BindNamed(query, arg)
+	if err != nil {
+		tx.setQueryErr("NamedGet", query, []interface{}{arg}, err)
+		return false
+	}
+	return tx.Get(dest, boundQuery, args...)
+}
+
+// Same as Select, but takes named parameters (struct or map) like NamedExec.
+func (tx *TxWrap) NamedSelect(dest interface{}, query string, arg interface{}) {
+	if tx.checkErr() {
+		return
+	}
+	boundQuery, args, err := tx.queryer().BindNamed(query, arg)
+	if err != nil {
+		tx.setQueryErr("NamedSelect", query, []interface{}{arg}, err)
+		return
+	}
+	tx.Select(dest, boundQuery, args...)
+}
+
+// Runs a query with named parameters and returns the rows.  Returns nil if
+// there is an error.  The caller must Close the returned rows, and errors from
+// iterating/scanning the rows must be handled manually (see Run or SetErr).
+func (tx *TxWrap) NamedQuery(query string, arg interface{}) *sqlx.Rows {
+	if tx.checkErr() {
 		return nil
 	}
-	rows, err := tx.Txx.QueryxContext(tx.ctx, query, args...)
+	boundQuery, args, err := tx.queryer().BindNamed(query, arg)
 	if err != nil {
-		tx.Err = err
+		tx.setQueryErr("NamedQuery", query, []interface{}{arg}, err)
+		return nil
+	}
+	startTs := tx.now()
+	rows, err := tx.queryer().QueryxContext(tx.ctx, boundQuery, args...)
+	tx.observeQuery("NamedQuery", boundQuery, args, startTs, err)
+	if err != nil {
+		tx.setQueryErr("NamedQuery", boundQuery, args, err)
 		return nil
 	}
+	return rows
+}
+
+// expands slice args for IN clauses (sqlx.In) and rebinds to the driver's bindvar type
+func (tx *TxWrap) expandIn(query string, args []interface{}) (string, []interface{}, bool) {
+	if tx.checkErr() {
+		return "", nil, false
+	}
+	inQuery, inArgs, err := sqlx.In(query, args...)
+	if err != nil {
+		tx.setQueryErr("In", query, args, err)
+		return "", nil, false
+	}
+	return tx.queryer().Rebind(inQuery), inArgs, true
+}
+
+// Same as Get, but expands slice arguments for IN (?) clauses using sqlx.In.
+func (tx *TxWrap) GetIn(dest interface{}, query string, args ...interface{}) bool {
+	inQuery, inArgs, ok := tx.expandIn(query, args)
+	if !ok {
+		return false
+	}
+	return tx.Get(dest, inQuery, inArgs...)
+}
+
+// Same as Select, but expands slice arguments for IN (?) clauses using sqlx.In.
+func (tx *TxWrap) SelectIn(dest interface{}, query string, args ...interface{}) {
+	inQuery, inArgs, ok := tx.expandIn(query, args)
+	if !ok {
+		return
+	}
+	tx.Select(dest, inQuery, inArgs...)
+}
+
+// Same as Exec, but expands slice arguments for IN (?) clauses using sqlx.In.
+func (tx *TxWrap) ExecIn(query string, args ...interface{}) sql.Result {
+	inQuery, inArgs, ok := tx.expandIn(query, args)
+	if !ok {
+		return errResult{tx.Err}
+	}
+	return tx.Exec(inQuery, inArgs...)
+}
+
+func (tx *TxWrap) SelectMaps(query string, args ...interface{}) []map[string]interface{} {
+	if tx.checkErr() {
+		return nil
+	}
+	startTs := tx.now()
 	var rtn []map[string]interface{}
+	err := tx.selectFunc(query, args, makeMapScanFn(func(m map[string]interface{}) error {
+		rtn = append(rtn, m)
+		return nil
+	}))
+	tx.observeQuery("SelectMaps", query, args, startTs, err)
+	if err != nil {
+		tx.setQueryErr("SelectMaps", query, args, err)
+		return nil
+	}
+	return rtn
+}
+
+// Same as SelectMaps, but streams each row (as a map) to 'fn' instead of returning
+// all of the rows.  The rows are always closed.  An error returned from 'fn' stops
+// the iteration and is set on tx.Err (as are errors from the query and from rows.Err).
+func (tx *TxWrap) SelectMapsFunc(query string, args []interface{}, fn func(m map[string]interface{}) error) {
+	if tx.checkErr() {
+		return
+	}
+	startTs := tx.now()
+	err := tx.selectFunc(query, args, makeMapScanFn(fn))
+	tx.observeQuery("SelectMapsFunc", query, args, startTs, err)
+	if err != nil {
+		tx.setQueryErr("SelectMapsFunc", query, args, err)
+	}
+}
+
+func makeMapScanFn(fn func(m map[string]interface{}) error) func(rows *sqlx.Rows) error {
+	numCols := -1
+	return func(rows *sqlx.Rows) error {
+		if numCols == -1 {
+			// Columns allocates, only call it for the first row
+			cols, err := rows.Columns()
+			if err != nil {
+				return err
+			}
+			numCols = len(cols)
+		}
+		m := make(map[string]interface{}, numCols)
+		err := rows.MapScan(m)
+		if err != nil {
+			return err
+		}
+		return fn(m)
+	}
+}
+
+// Streams the rows of a query to 'fn' one row at a time (use rows.Scan, rows.StructScan,
+// or rows.MapScan), so large result sets do not need to be loaded into memory.  The
+// rows are always closed.  An error returned from 'fn' stops the iteration and is set
+// on tx.Err (as are errors from the query and from rows.Err).
+func (tx *TxWrap) SelectFunc(query string, args []interface{}, fn func(rows *sqlx.Rows) error) {
+	if tx.checkErr() {
+		return
+	}
+	startTs := tx.now()
+	err := tx.selectFunc(query, args, fn)
+	tx.observeQuery("SelectFunc", query, args, startTs, err)
+	if err != nil {
+		tx.setQueryErr("SelectFunc", query, args, err)
+	}
+}
+
+func (tx *TxWrap) selectFunc(query string, args []interface{}, fn func(rows *sqlx.Rows) error) error {
+	rows, err := tx.queryer().QueryxContext(tx.ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 	for rows.Next() {
-		m := make(map[string]interface{})
-		err = rows.MapScan(m)
+		err = fn(rows)
 		if err != nil {
-			tx.Err = err
-			return nil
+			return err
 		}
-		rtn = append(rtn, m)
 	}
-	return rtn
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+	return rows.Close()
 }
 
 func (tx *TxWrap) GetMap(query string, args ...interface{}) map[string]interface{} {
-	if tx.Err != nil {
-		return nil
+	m, _ := tx.GetMapOk(query, args...)
+	return m
+}
+
+// Returns the row as a map and true if a row was found.  Returns (nil, false)
+// for sql.ErrNoRows or an error.
+func (tx *TxWrap) GetMapOk(query string, args ...interface{}) (map[string]interface{}, bool) {
+	if tx.checkErr() {
+		return nil, false
 	}
-	row := tx.Txx.QueryRowxContext(tx.ctx, query, args...)
+	startTs := tx.now()
 	m := make(map[string]interface{})
-	err := row.MapScan(m)
+	err := tx.prepareRowStmt(query)
+	if err == nil {
+		err = tx.queryer().QueryRowxContext(tx.ctx, query, args...).MapScan(m)
+	}
+	tx.observeQuery("GetMap", query, args, startTs, err)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil
+			if tx.isStrictNoRows() {
+				tx.setQueryErr("GetMap", query, args, noRowsError(query))
+			}
+			return nil, false
 		}
-		tx.Err = err
-		return nil
+		tx.setQueryErr("GetMap", query, args, err)
+		return nil, false
 	}
-	return m
+	return m, true
+}
+
+// Same as SelectMaps, but also returns the column names in query order so
+// the maps can be rendered in a stable column order (e.g. for CSV output).
+func (tx *TxWrap) SelectOrderedMaps(query string, args ...interface{}) ([]string, []map[string]interface{}) {
+	if tx.checkErr() {
+		return nil, nil
+	}
+	startTs := tx.now()
+	cols, rtn, err := tx.selectOrderedMaps(query, args)
+	tx.observeQuery("SelectOrderedMaps", query, args, startTs, err)
+	if err != nil {
+		tx.setQueryErr("SelectOrderedMaps", query, args, err)
+		return nil, nil
+	}
+	return cols, rtn
+}
+
+func (tx *TxWrap) selectOrderedMaps(query string, args []interface{}) ([]string, []map[string]interface{}, error) {
+	rows, err := tx.queryer().QueryxContext(tx.ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	var rtn []map[string]interface{}
+	for rows.Next() {
+		m := make(map[string]interface{}, len(cols))
+		err = rows.MapScan(m)
+		if err != nil {
+			return nil, nil, err
+		}
+		rtn = append(rtn, m)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, nil, err
+	}
+	return cols, rtn, nil
 }
 
 // Runs a function iff there has been no error
 func (tx *TxWrap) Run(fn func() error) {
-	if tx.Err != nil {
+	if tx.checkErr() {
 		return
 	}
 	err := fn()
@@ -272,6 +1479,62 @@ func (tx *TxWrap) Run(fn func() error) {
 	}
 }
 
+// Registers a validation callback to run after the outermost 'fn' returns (so all of
+// the writes in the transaction are done) but before Commit, e.g. to check an
+// invariant across the rows the transaction changed.  If a callback returns an error
+// (or a DB call inside of it fails) the transaction is rolled back and WithTx returns
+// that error.  Callbacks run in registration order, and are not run if 'fn' failed.
+// Callbacks may run DB calls (and register more BeforeCommit callbacks).  Callbacks
+// registered inside of a savepoint that is rolled back are discarded.  Not run for
+// NewFromTx or RunInExistingTx (TxWrap does not commit those transactions).
+func (tx *TxWrap) BeforeCommit(fn func(tx *TxWrap) error) {
+	tx.beforeHooks = append(tx.beforeHooks, fn)
+}
+
+func (tx *TxWrap) runBeforeCommitHooks() {
+	for idx := 0; idx < len(tx.beforeHooks) && tx.Err == nil; idx++ {
+		err := tx.beforeHooks[idx](tx)
+		tx.joinCollectedErrs()
+		if tx.Err == nil && err != nil {
+			tx.Err = err
+		}
+	}
+}
+
+// Registers a callback to run after the outermost transaction successfully commits.
+// Callbacks run in registration order and are not run if the transaction is rolled back.
+// Errors returned from callbacks are joined (errors.Join) and returned from WithTx.
+// Note that in that case the data has *already been committed*, the error only
+// reports that a post-commit action (e.g. sending a notification) failed.
+func (tx *TxWrap) OnCommit(fn func() error) {
+	tx.commitHooks = append(tx.commitHooks, fn)
+}
+
+// Registers a callback to run after the outermost transaction is rolled back (or
+// fails to commit).  'err' is the error that caused the rollback.  Callbacks run
+// in registration order.  If WithRetry is used, callbacks registered during a
+// failed attempt run after that attempt is rolled back.
+func (tx *TxWrap) OnRollback(fn func(err error)) {
+	tx.rbHooks = append(tx.rbHooks, fn)
+}
+
+func (tx *TxWrap) runRollbackHooks(err error) {
+	for _, fn := range tx.rbHooks {
+		fn(err)
+	}
+}
+
+func (tx *TxWrap) runCommitHooks() error {
+	var errs []error
+	for _, fn := range tx.commitHooks {
+		err := fn()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (tx *TxWrap) SetErr(err error) {
 	if tx.Err == nil {
 		tx.Err = err
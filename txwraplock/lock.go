@@ -0,0 +1,111 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+// Database-backed mutex on top of txwrap ("only one worker does X").  WithLock
+// runs a function in a transaction while holding a named lock, the lock is released
+// when the transaction commits or rolls back (so it cannot leak if the process
+// dies).  On Postgres the lock is a transaction-scoped advisory lock (no table is
+// needed).  On other databases it is a row lock on a locks table:
+//
+//	CREATE TABLE txwrap_locks (
+//	    name varchar(255) PRIMARY KEY,
+//	    locked_ts bigint NOT NULL
+//	);
+//
+// On SQLite the lock is the database write lock, set a busy timeout (and use
+// immediate transactions) so waiting workers do not fail with SQLITE_BUSY.
+//
+// Usage:
+//
+//	err := txwraplock.WithLock(ctx, db, "nightly-report", func(tx *txwrap.TxWrap) error {
+//	    ...
+//	})
+package txwraplock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sawka/txwrap"
+)
+
+const DefaultTable = "txwrap_locks"
+
+type Locker struct {
+	DB    *sqlx.DB
+	Table string // locks table, not used for Postgres unless UseTable is set
+
+	// Use the locks table on Postgres as well (instead of advisory locks)
+	UseTable bool
+}
+
+// Creates a Locker using 'table' (DefaultTable if empty).  The table name is not
+// quoted or escaped, it must not come from user input.
+func New(db *sqlx.DB, table string) *Locker {
+	if table == "" {
+		table = DefaultTable
+	}
+	return &Locker{DB: db, Table: table}
+}
+
+// Runs 'fn' with the lock 'key' held, using the default Locker (see New)
+func WithLock(ctx context.Context, db *sqlx.DB, key string, fn func(tx *txwrap.TxWrap) error, opts ...txwrap.TxOption) error {
+	return New(db, "").WithLock(ctx, key, fn, opts...)
+}
+
+// Runs 'fn' in a transaction (WithTx) with the lock 'key' held.  Waits until the
+// lock is available (use txwrap.WithTxTimeout, or a Context with a deadline, to
+// limit the wait).  The lock is released when the transaction ends.  If ctx is
+// already running a TxWrap transaction, the lock is taken in the outer transaction
+// and held until it ends.
+func (l *Locker) WithLock(ctx context.Context, key string, fn func(tx *txwrap.TxWrap) error, opts ...txwrap.TxOption) error {
+	if l.DB == nil {
+		return fmt.Errorf("txwraplock, invalid nil DB")
+	}
+	return txwrap.WithTx(ctx, l.DB, func(tx *txwrap.TxWrap) error {
+		l.Lock(tx, key)
+		if tx.Err != nil {
+			return tx.Err
+		}
+		return fn(tx)
+	}, opts...)
+}
+
+// Takes the lock 'key' in an existing transaction, it is held until the transaction
+// ends.  Errors are set on tx.
+func (l *Locker) Lock(tx *txwrap.TxWrap, key string) {
+	if l.useAdvisoryLock() {
+		tx.AdvisoryXactLock(txwrap.AdvisoryLockKey(key))
+		return
+	}
+	l.lockRow(tx, key)
+}
+
+func (l *Locker) useAdvisoryLock() bool {
+	return !l.UseTable && sqlx.BindType(l.DB.DriverName()) == sqlx.DOLLAR
+}
+
+// updating the lock row takes a row (write) lock that is held until the transaction
+// ends.  if the row does not exist yet it is inserted (inside of a savepoint, so a
+// concurrent insert of the same key falls back to the update, which waits).
+func (l *Locker) lockRow(tx *txwrap.TxWrap, key string) {
+	nowMs := time.Now().UnixMilli()
+	updateQuery := tx.Rebind(fmt.Sprintf(`UPDATE %s SET locked_ts = ? WHERE name = ?`, l.Table))
+	if tx.ExecAffected(updateQuery, nowMs, key) > 0 || tx.Err != nil {
+		return
+	}
+	insertQuery := tx.Rebind(fmt.Sprintf(`INSERT INTO %s (name, locked_ts) VALUES (?, ?)`, l.Table))
+	err := txwrap.WithSavepoint(tx, func() error {
+		tx.Exec(insertQuery, key, nowMs)
+		return nil
+	})
+	if err != nil && txwrap.IsUniqueViolation(err) {
+		tx.Exec(updateQuery, nowMs, key)
+		return
+	}
+	if err != nil {
+		tx.SetErr(err)
+	}
+}
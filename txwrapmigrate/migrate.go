@@ -0,0 +1,245 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+// Minimal schema migration runner on top of txwrap.  Migrations are registered with
+// an increasing version number (as Go funcs or SQL scripts), applied versions are
+// recorded in a migrations table, and each migration runs in its own transaction
+// (WithTx) together with the insert of its version, so a failed migration leaves no
+// trace.  Every migration transaction holds a txwraplock lock, so app instances that
+// start at the same time do not race (the others wait and then skip the versions
+// that were already applied).
+//
+// The migrations table is created automatically (CREATE TABLE IF NOT EXISTS).  On
+// databases other than Postgres the lock needs the txwraplock locks table, which
+// must exist before Up is called (see txwraplock).
+//
+// Note that DDL is not transactional on every database (e.g. MySQL commits
+// implicitly), there a failed migration may be partially applied.
+//
+// Usage:
+//
+//	//go:embed migrations/*.sql
+//	var migrationsFS embed.FS
+//
+//	m := txwrapmigrate.New(db, "")
+//	err := m.RegisterFS(migrationsFS, "migrations") // 0001_create_users.sql, 0002_add_email.sql, ...
+//	m.Register(3, "backfill_email", func(tx *txwrap.TxWrap) error {
+//	    ...
+//	})
+//	numApplied, err := m.Up(ctx)
+package txwrapmigrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sawka/txwrap"
+	"github.com/sawka/txwrap/txwraplock"
+)
+
+const DefaultTable = "schema_migrations"
+
+const lockKeyPrefix = "txwrapmigrate:"
+
+type Migration struct {
+	Version int64
+	Name    string
+	Fn      func(tx *txwrap.TxWrap) error
+}
+
+type Migrator struct {
+	DB    *sqlx.DB
+	Table string // migrations table
+
+	Locker *txwraplock.Locker // lock held while migrating, txwraplock.New(DB, "") if nil
+	TxOpts []txwrap.TxOption  // options for the migration transactions
+
+	migrations []Migration
+}
+
+// Creates a Migrator using 'table' (DefaultTable if empty).  The table name is not
+// quoted or escaped, it must not come from user input.
+func New(db *sqlx.DB, table string) *Migrator {
+	if table == "" {
+		table = DefaultTable
+	}
+	return &Migrator{DB: db, Table: table}
+}
+
+// Registers a migration.  Migrations are applied in version order (not registration
+// order), versions must be unique and > 0 (checked by Up).
+func (m *Migrator) Register(version int64, name string, fn func(tx *txwrap.TxWrap) error) {
+	m.migrations = append(m.migrations, Migration{Version: version, Name: name, Fn: fn})
+}
+
+// Registers a migration that runs a SQL script (see TxWrap.ExecScript)
+func (m *Migrator) RegisterSQL(version int64, name string, script string) {
+	m.Register(version, name, func(tx *txwrap.TxWrap) error {
+		tx.ExecScript(script)
+		return tx.Err
+	})
+}
+
+// Registers every .sql file in 'dir' of 'fsys' (e.g. an embed.FS) as a migration.
+// File names must start with the version followed by an underscore, the rest of the
+// name (without .sql) is the migration name, e.g. "0002_add_email.sql".
+func (m *Migrator) RegisterFS(fsys fs.FS, dir string) error {
+	fileNames, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return fmt.Errorf("txwrapmigrate reading %q: %w", dir, err)
+	}
+	for _, fileName := range fileNames {
+		baseName := strings.TrimSuffix(path.Base(fileName), ".sql")
+		versionStr, name, ok := strings.Cut(baseName, "_")
+		version, convErr := strconv.ParseInt(versionStr, 10, 64)
+		if !ok || convErr != nil {
+			return fmt.Errorf("txwrapmigrate invalid migration file name %q, must be <version>_<name>.sql", fileName)
+		}
+		barr, err := fs.ReadFile(fsys, fileName)
+		if err != nil {
+			return fmt.Errorf("txwrapmigrate reading %q: %w", fileName, err)
+		}
+		m.RegisterSQL(version, name, string(barr))
+	}
+	return nil
+}
+
+// Applies the registered migrations that have not been applied yet (in version
+// order), each in its own transaction.  Stops at the first migration that fails.
+// Returns the number of migrations applied by this call.  Must not be called inside
+// of a transaction (the migrations would all run in the caller's transaction).
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	migrations, err := m.sortedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	applied, err := m.appliedSet(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var numApplied int
+	for _, mig := range migrations {
+		if applied[mig.Version] {
+			continue
+		}
+		ran, err := m.apply(ctx, mig)
+		if err != nil {
+			return numApplied, fmt.Errorf("txwrapmigrate migration %d (%s): %w", mig.Version, mig.Name, err)
+		}
+		if ran {
+			numApplied++
+		}
+	}
+	return numApplied, nil
+}
+
+// Returns the applied versions (sorted)
+func (m *Migrator) Applied(ctx context.Context) ([]int64, error) {
+	var rtn []int64
+	err := m.withLock(ctx, func(tx *txwrap.TxWrap) error {
+		rtn = m.selectApplied(tx)
+		return nil
+	})
+	return rtn, err
+}
+
+// Returns the registered migrations that have not been applied yet (sorted)
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	migrations, err := m.sortedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.appliedSet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rtn []Migration
+	for _, mig := range migrations {
+		if !applied[mig.Version] {
+			rtn = append(rtn, mig)
+		}
+	}
+	return rtn, nil
+}
+
+// runs one migration, returns false if it was already applied (by another instance
+// while this one was waiting for the lock)
+func (m *Migrator) apply(ctx context.Context, mig Migration) (bool, error) {
+	var ran bool
+	err := m.withLock(ctx, func(tx *txwrap.TxWrap) error {
+		ran = false
+		selectQuery := tx.Rebind(fmt.Sprintf(`SELECT 1 FROM %s WHERE version = ?`, m.Table))
+		if tx.Exists(selectQuery, mig.Version) || tx.Err != nil {
+			return nil
+		}
+		if err := mig.Fn(tx); err != nil {
+			return err
+		}
+		insertQuery := tx.Rebind(fmt.Sprintf(`INSERT INTO %s (version, name, applied_ts) VALUES (?, ?, ?)`, m.Table))
+		tx.Exec(insertQuery, mig.Version, mig.Name, time.Now().UnixMilli())
+		ran = true
+		return nil
+	})
+	return ran, err
+}
+
+func (m *Migrator) appliedSet(ctx context.Context) (map[int64]bool, error) {
+	versions, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rtn := make(map[int64]bool, len(versions))
+	for _, version := range versions {
+		rtn[version] = true
+	}
+	return rtn, nil
+}
+
+func (m *Migrator) selectApplied(tx *txwrap.TxWrap) []int64 {
+	return tx.SelectInt64s(fmt.Sprintf(`SELECT version FROM %s ORDER BY version`, m.Table))
+}
+
+// runs 'fn' in a transaction holding the migration lock (the migrations table is
+// created first if it does not exist)
+func (m *Migrator) withLock(ctx context.Context, fn func(tx *txwrap.TxWrap) error) error {
+	if m.DB == nil {
+		return fmt.Errorf("txwrapmigrate, invalid nil DB")
+	}
+	locker := m.Locker
+	if locker == nil {
+		locker = txwraplock.New(m.DB, "")
+	}
+	return locker.WithLock(ctx, lockKeyPrefix+m.Table, func(tx *txwrap.TxWrap) error {
+		tx.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (version bigint PRIMARY KEY, name varchar(255) NOT NULL, applied_ts bigint NOT NULL)`, m.Table))
+		if tx.Err != nil {
+			return tx.Err
+		}
+		return fn(tx)
+	}, m.TxOpts...)
+}
+
+func (m *Migrator) sortedMigrations() ([]Migration, error) {
+	rtn := append([]Migration(nil), m.migrations...)
+	sort.SliceStable(rtn, func(i, j int) bool {
+		return rtn[i].Version < rtn[j].Version
+	})
+	for idx, mig := range rtn {
+		if mig.Version <= 0 {
+			return nil, fmt.Errorf("txwrapmigrate invalid migration version %d (%s), must be > 0", mig.Version, mig.Name)
+		}
+		if mig.Fn == nil {
+			return nil, fmt.Errorf("txwrapmigrate migration %d (%s) has a nil func", mig.Version, mig.Name)
+		}
+		if idx > 0 && rtn[idx-1].Version == mig.Version {
+			return nil, fmt.Errorf("txwrapmigrate duplicate migration version %d (%s, %s)", mig.Version, rtn[idx-1].Name, mig.Name)
+		}
+	}
+	return rtn, nil
+}
@@ -0,0 +1,18 @@
+module github.com/sawka/txwrap/txwrapotel
+
+go 1.22
+
+require (
+	github.com/sawka/txwrap v0.0.0
+	go.opentelemetry.io/otel v1.32.0
+	go.opentelemetry.io/otel/trace v1.32.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/jmoiron/sqlx v1.4.0 // indirect
+	go.opentelemetry.io/otel/metric v1.32.0 // indirect
+)
+
+replace github.com/sawka/txwrap => ../
@@ -0,0 +1,140 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+// OpenTelemetry tracing for txwrap.  Creates a span for each transaction started
+// by WithTx and a child span for every DB call made through the TxWrap.
+//
+// Usage:
+//
+//	err := txwrap.WithTx(ctx, db, fn, txwrapotel.Tracing())
+package txwrapotel
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sawka/txwrap"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/sawka/txwrap/txwrapotel"
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	dbSystem       string
+	sanitize       func(query string) string
+}
+
+type Option func(cfg *config)
+
+// Sets the TracerProvider, defaults to otel.GetTracerProvider()
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(cfg *config) {
+		cfg.tracerProvider = tp
+	}
+}
+
+// Sets the db.system attribute (e.g. "postgresql", "sqlite") on all spans
+func WithDBSystem(dbSystem string) Option {
+	return func(cfg *config) {
+		cfg.dbSystem = dbSystem
+	}
+}
+
+// Sets a function to sanitize the query text before it is recorded as the
+// db.statement attribute.  Query arguments are never recorded.
+func WithSanitizer(fn func(query string) string) Option {
+	return func(cfg *config) {
+		cfg.sanitize = fn
+	}
+}
+
+// Returns a TxOption that traces the transaction and all of its queries.
+func Tracing(opts ...Option) txwrap.TxOption {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.tracerProvider == nil {
+		cfg.tracerProvider = otel.GetTracerProvider()
+	}
+	tracer := cfg.tracerProvider.Tracer(instrumentationName)
+	return txwrap.CombineOptions(
+		txwrap.WithTxHook(cfg.txHook(tracer)),
+		txwrap.WithQueryHook(cfg.queryHook(tracer)),
+	)
+}
+
+func (cfg *config) baseAttrs() []attribute.KeyValue {
+	if cfg.dbSystem == "" {
+		return nil
+	}
+	return []attribute.KeyValue{attribute.String("db.system", cfg.dbSystem)}
+}
+
+func (cfg *config) txHook(tracer trace.Tracer) txwrap.TxHook {
+	return func(ctx context.Context) (context.Context, func(info txwrap.TxInfo)) {
+		ctx, span := tracer.Start(ctx, "txwrap.WithTx",
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(cfg.baseAttrs()...),
+		)
+		return ctx, func(info txwrap.TxInfo) {
+			span.SetAttributes(
+				attribute.Int("txwrap.attempts", info.Attempts),
+				attribute.Bool("txwrap.committed", info.Committed),
+			)
+			if info.Err != nil {
+				span.RecordError(info.Err)
+				span.SetStatus(codes.Error, info.Err.Error())
+			}
+			span.End()
+		}
+	}
+}
+
+func (cfg *config) queryHook(tracer trace.Tracer) txwrap.QueryHook {
+	return func(ctx context.Context, info txwrap.QueryInfo) {
+		endTs := time.Now()
+		query := info.Query
+		if cfg.sanitize != nil {
+			query = cfg.sanitize(query)
+		}
+		attrs := append(cfg.baseAttrs(),
+			attribute.String("db.operation", info.Op),
+			attribute.String("db.statement", query),
+		)
+		if info.RowsAffected >= 0 {
+			attrs = append(attrs, attribute.Int64("db.rows_affected", info.RowsAffected))
+		}
+		_, span := tracer.Start(ctx, "txwrap."+info.Op,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithTimestamp(endTs.Add(-info.Duration)),
+			trace.WithAttributes(attrs...),
+		)
+		if info.Err != nil && !errors.Is(info.Err, sql.ErrNoRows) {
+			span.RecordError(info.Err)
+			span.SetStatus(codes.Error, info.Err.Error())
+		}
+		span.End(trace.WithTimestamp(endTs))
+	}
+}
+
+// Returns the W3C traceparent of the span in 'ctx' as a sqlcommenter tag, for use
+// with txwrap.WithSQLCommenter.  Returns nil if there is no valid span.
+//
+//	txwrap.WithSQLCommenter(txwrapotel.CommentTags)
+func CommentTags(ctx context.Context) map[string]string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return map[string]string{
+		"traceparent": fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags()),
+	}
+}
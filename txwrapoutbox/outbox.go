@@ -0,0 +1,176 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+// Transactional outbox on top of txwrap.  Messages are written to an outbox table
+// inside of the caller's transaction (so they are only published if the transaction
+// commits), and a Dispatcher reads committed messages, passes them to a handler,
+// and marks them as dispatched.  Delivery is at-least-once, handlers must be
+// idempotent.
+//
+// The outbox table must have the following columns (Postgres shown):
+//
+//	CREATE TABLE txwrap_outbox (
+//	    id bigserial PRIMARY KEY,
+//	    topic text NOT NULL,
+//	    payload bytea NOT NULL,
+//	    created_ts timestamptz NOT NULL,
+//	    dispatched_ts timestamptz
+//	);
+//	CREATE INDEX txwrap_outbox_pending ON txwrap_outbox (id) WHERE dispatched_ts IS NULL;
+//
+// Usage:
+//
+//	ob := txwrapoutbox.New("")
+//	txwrap.WithTx(ctx, db, func(tx *txwrap.TxWrap) error {
+//	    tx.Exec(`INSERT INTO orders ...`)
+//	    ob.EnqueueJSON(tx, "order.created", order)
+//	    return nil
+//	})
+//
+//	d := &txwrapoutbox.Dispatcher{DB: db, Outbox: ob, Handler: publishFn}
+//	go d.Run(ctx)
+package txwrapoutbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sawka/txwrap"
+)
+
+const DefaultTable = "txwrap_outbox"
+const DefaultBatchSize = 100
+const DefaultPollInterval = time.Second
+
+type Message struct {
+	Id        int64     `db:"id"`
+	Topic     string    `db:"topic"`
+	Payload   []byte    `db:"payload"`
+	CreatedTs time.Time `db:"created_ts"`
+}
+
+type Outbox struct {
+	Table string
+}
+
+// Creates an Outbox using 'table' (DefaultTable if empty).  The table name is not
+// quoted or escaped, it must not come from user input.
+func New(table string) *Outbox {
+	if table == "" {
+		table = DefaultTable
+	}
+	return &Outbox{Table: table}
+}
+
+// Writes a message to the outbox inside of 'tx'.  Errors are set on the Tx, same
+// as the other Tx methods.
+func (o *Outbox) Enqueue(tx txwrap.Tx, topic string, payload []byte) {
+	query := fmt.Sprintf(`INSERT INTO %s (topic, payload, created_ts) VALUES (:topic, :payload, :created_ts)`, o.Table)
+	tx.NamedExec(query, map[string]interface{}{
+		"topic":      topic,
+		"payload":    payload,
+		"created_ts": time.Now().UTC(),
+	})
+}
+
+// Same as Enqueue, but marshals 'v' as JSON for the payload
+func (o *Outbox) EnqueueJSON(tx txwrap.Tx, topic string, v interface{}) {
+	barr, err := json.Marshal(v)
+	if err != nil {
+		tx.SetErr(fmt.Errorf("outbox marshal %q message: %w", topic, err))
+		return
+	}
+	o.Enqueue(tx, topic, barr)
+}
+
+// Reads pending messages from an Outbox and passes them (in id order) to Handler.
+type Dispatcher struct {
+	DB      *sqlx.DB
+	Outbox  *Outbox
+	Handler func(ctx context.Context, msg Message) error
+
+	BatchSize    int           // messages per transaction, DefaultBatchSize if 0
+	PollInterval time.Duration // wait between polls when there are no messages, DefaultPollInterval if 0
+
+	// Set for Postgres (or MySQL 8) to lock the batch with FOR UPDATE SKIP LOCKED so
+	// multiple dispatchers can run concurrently.  Without it, run one dispatcher.
+	SkipLocked bool
+
+	OnError func(err error)   // called by Run for errors (optional)
+	TxOpts  []txwrap.TxOption // options for the dispatch transactions
+}
+
+// Dispatches one batch of pending messages.  Each message that Handler accepts is
+// marked as dispatched.  If Handler returns an error, the batch stops (messages
+// already handled are still marked) and the error is returned, the failed message
+// is retried on the next call.  Returns the number of messages dispatched.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	batchSize := d.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	var numDispatched int
+	var handlerErr error
+	txErr := txwrap.WithTx(ctx, d.DB, func(tx *txwrap.TxWrap) error {
+		numDispatched = 0
+		handlerErr = nil
+		query := fmt.Sprintf(`SELECT id, topic, payload, created_ts FROM %s WHERE dispatched_ts IS NULL ORDER BY id LIMIT ?`, d.Outbox.Table)
+		if d.SkipLocked {
+			query += ` FOR UPDATE SKIP LOCKED`
+		}
+		var msgs []Message
+		tx.Select(&msgs, tx.Txx.Rebind(query), batchSize)
+		markQuery := tx.Txx.Rebind(fmt.Sprintf(`UPDATE %s SET dispatched_ts = ? WHERE id = ?`, d.Outbox.Table))
+		for _, msg := range msgs {
+			if tx.Err != nil {
+				break
+			}
+			err := d.Handler(ctx, msg)
+			if err != nil {
+				handlerErr = fmt.Errorf("outbox handler message %d (%s): %w", msg.Id, msg.Topic, err)
+				break
+			}
+			tx.Exec(markQuery, time.Now().UTC(), msg.Id)
+			numDispatched++
+		}
+		return nil
+	}, d.TxOpts...)
+	if txErr != nil {
+		return 0, txErr
+	}
+	return numDispatched, handlerErr
+}
+
+// Dispatches messages until 'ctx' is done (returns ctx.Err()).  When a full batch
+// is dispatched the next batch runs immediately, otherwise Run waits PollInterval.
+// Errors are passed to OnError and retried after PollInterval.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	pollInterval := d.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+	batchSize := d.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	for {
+		num, err := d.DispatchOnce(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil && d.OnError != nil {
+			d.OnError(err)
+		}
+		if err == nil && num >= batchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+// TxWrap for the native jackc/pgx (v5) driver.  Provides the same error-latching
+// transaction model as txwrap, but runs on a pgx.Tx (e.g. from a *pgxpool.Pool)
+// instead of database/sql.  Once a DB call fails, all future calls are skipped and
+// the transaction will be rolled back.
+package txwrappgx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Wraps a pgx.Tx.  Not thread-safe (same as txwrap.TxWrap).
+type TxWrap struct {
+	Tx  pgx.Tx
+	Err error
+
+	ctx context.Context
+}
+
+// Implemented by *pgxpool.Pool, *pgx.Conn, and pgx.Tx
+type Beginner interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+// context-key
+type txWrapKey struct{}
+
+// Checks to see if the given Context is running a txwrappgx transaction
+func IsTxWrapContext(ctx context.Context) bool {
+	return ctx.Value(txWrapKey{}) != nil
+}
+
+// Same semantics as txwrap.WithTx.  Nested calls (using tx.Context()) reuse the
+// outer transaction.
+func WithTx(ctx context.Context, db Beginner, fn func(tx *TxWrap) error) error {
+	return WithTxOptions(ctx, db, pgx.TxOptions{}, fn)
+}
+
+// Same as WithTx, but passes 'txOptions' (isolation level, access mode) to BeginTx.
+// 'txOptions' is ignored for nested calls.
+func WithTxOptions(ctx context.Context, db Beginner, txOptions pgx.TxOptions, fn func(tx *TxWrap) error) (rtnErr error) {
+	ctxVal := ctx.Value(txWrapKey{})
+	if ctxVal != nil {
+		txWrap := ctxVal.(*TxWrap)
+		if txWrap.Err != nil {
+			return txWrap.Err
+		}
+		fnErr := fn(txWrap)
+		if txWrap.Err == nil && fnErr != nil {
+			txWrap.Err = fnErr
+		}
+		return txWrap.Err
+	}
+	if db == nil || (reflect.ValueOf(db).Kind() == reflect.Pointer && reflect.ValueOf(db).IsNil()) {
+		return fmt.Errorf("invalid nil DB passed to WithTx")
+	}
+	tx, beginErr := db.BeginTx(ctx, txOptions)
+	if beginErr != nil {
+		return beginErr
+	}
+	txWrap := &TxWrap{Tx: tx, ctx: ctx}
+	defer func() {
+		if p := recover(); p != nil {
+			txWrap.Tx.Rollback(context.WithoutCancel(ctx))
+			panic(p)
+		}
+		if rtnErr != nil {
+			txWrap.Tx.Rollback(context.WithoutCancel(ctx))
+			return
+		}
+		rtnErr = txWrap.Tx.Commit(ctx)
+	}()
+	fnErr := fn(txWrap)
+	if txWrap.Err == nil && fnErr != nil {
+		txWrap.Err = fnErr
+	}
+	return txWrap.Err
+}
+
+func WithTxRtn[RT any](ctx context.Context, db Beginner, fn func(tx *TxWrap) (RT, error)) (RT, error) {
+	var rtn RT
+	txErr := WithTx(ctx, db, func(tx *TxWrap) error {
+		temp, err := fn(tx)
+		if err != nil {
+			return err
+		}
+		rtn = temp
+		return nil
+	})
+	return rtn, txErr
+}
+
+// Returns the TxWrap Context (with the txWrapKey).
+// Must use this Context for nested calls to WithTx
+func (tx *TxWrap) Context() context.Context {
+	return context.WithValue(tx.ctx, txWrapKey{}, tx)
+}
+
+func (tx *TxWrap) Exec(query string, args ...interface{}) pgconn.CommandTag {
+	if tx.Err != nil {
+		return pgconn.CommandTag{}
+	}
+	tag, err := tx.Tx.Exec(tx.ctx, query, args...)
+	if err != nil {
+		tx.Err = err
+	}
+	return tag
+}
+
+// Bulk loads rows into 'table' using the COPY protocol (pgx.Tx.CopyFrom).
+// Returns the number of rows copied (0 on error).
+func (tx *TxWrap) CopyFrom(table pgx.Identifier, columns []string, rows [][]interface{}) int64 {
+	return tx.CopyFromSource(table, columns, pgx.CopyFromRows(rows))
+}
+
+// Same as CopyFrom, but streams rows from a pgx.CopyFromSource (e.g.
+// pgx.CopyFromFunc or pgx.CopyFromSlice).
+func (tx *TxWrap) CopyFromSource(table pgx.Identifier, columns []string, src pgx.CopyFromSource) int64 {
+	if tx.Err != nil {
+		return 0
+	}
+	numRows, err := tx.Tx.CopyFrom(tx.ctx, table, columns, src)
+	if err != nil {
+		tx.Err = err
+		return 0
+	}
+	return numRows
+}
+
+// Scans the first row into 'dest' (one destination per column, like pgx.Row.Scan).
+// Returns false if there is an error or no rows (pgx.ErrNoRows does not set tx.Err).
+func (tx *TxWrap) Scan(query string, args []interface{}, dest ...interface{}) bool {
+	if tx.Err != nil {
+		return false
+	}
+	err := tx.Tx.QueryRow(tx.ctx, query, args...).Scan(dest...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false
+	}
+	if err != nil {
+		tx.Err = err
+		return false
+	}
+	return true
+}
+
+// Returns false if there is an error or the query returns no rows.
+func (tx *TxWrap) Exists(query string, args ...interface{}) bool {
+	if tx.Err != nil {
+		return false
+	}
+	rows, err := tx.Tx.Query(tx.ctx, query, args...)
+	if err != nil {
+		tx.Err = err
+		return false
+	}
+	defer rows.Close()
+	found := rows.Next()
+	if rows.Err() != nil {
+		tx.Err = rows.Err()
+		return false
+	}
+	return found
+}
+
+func (tx *TxWrap) GetString(query string, args ...interface{}) string {
+	var rtnStr *string
+	tx.Scan(query, args, &rtnStr)
+	if rtnStr == nil {
+		return ""
+	}
+	return *rtnStr
+}
+
+func (tx *TxWrap) GetInt64(query string, args ...interface{}) int64 {
+	var rtnInt *int64
+	tx.Scan(query, args, &rtnInt)
+	if rtnInt == nil {
+		return 0
+	}
+	return *rtnInt
+}
+
+func (tx *TxWrap) GetBool(query string, args ...interface{}) bool {
+	var rtnBool *bool
+	tx.Scan(query, args, &rtnBool)
+	if rtnBool == nil {
+		return false
+	}
+	return *rtnBool
+}
+
+// Returns the first row as RT and true.  Structs are scanned by column name
+// (pgx.RowToStructByName), other types must be a single column.  Returns the zero
+// value and false if there is an error or no rows.
+func Get[RT any](tx *TxWrap, query string, args ...interface{}) (RT, bool) {
+	var zero RT
+	if tx.Err != nil {
+		return zero, false
+	}
+	rows, err := tx.Tx.Query(tx.ctx, query, args...)
+	if err != nil {
+		tx.Err = err
+		return zero, false
+	}
+	rtn, err := pgx.CollectOneRow(rows, rowToFn[RT]())
+	if errors.Is(err, pgx.ErrNoRows) {
+		return zero, false
+	}
+	if err != nil {
+		tx.Err = err
+		return zero, false
+	}
+	return rtn, true
+}
+
+// Returns all rows as a []RT (see Get for how rows are scanned).  Returns nil
+// if there is an error.
+func Select[RT any](tx *TxWrap, query string, args ...interface{}) []RT {
+	if tx.Err != nil {
+		return nil
+	}
+	rows, err := tx.Tx.Query(tx.ctx, query, args...)
+	if err != nil {
+		tx.Err = err
+		return nil
+	}
+	rtn, err := pgx.CollectRows(rows, rowToFn[RT]())
+	if err != nil {
+		tx.Err = err
+		return nil
+	}
+	return rtn
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// structs are scanned by name, unless they can scan a column themselves (time.Time, pgtype.*)
+func rowToFn[RT any]() pgx.RowToFunc[RT] {
+	rt := reflect.TypeOf((*RT)(nil)).Elem()
+	if rt.Kind() == reflect.Struct && rt != timeType && !reflect.PointerTo(rt).Implements(scannerType) {
+		return pgx.RowToStructByName[RT]
+	}
+	return pgx.RowTo[RT]
+}
+
+// Runs a function iff there has been no error
+func (tx *TxWrap) Run(fn func() error) {
+	if tx.Err != nil {
+		return
+	}
+	err := fn()
+	if err != nil {
+		tx.Err = err
+	}
+}
+
+func (tx *TxWrap) SetErr(err error) {
+	if tx.Err == nil {
+		tx.Err = err
+	}
+}
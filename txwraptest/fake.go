@@ -0,0 +1,484 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+// Test helpers for code built on txwrap.  FakeTx implements txwrap.Tx without a
+// database: it records every query and returns canned results.
+//
+// Usage:
+//
+//	fake := txwraptest.NewFakeTx()
+//	fake.AddResult(`SELECT name FROM users WHERE id = ?`, "mike")
+//	name := repo.GetUserName(fake, 5)
+//	// check name, fake.Queries, fake.Err
+//
+// To test the transaction itself (begin/commit/rollback and the exact SQL) against
+// go-sqlmock, see the txwraptest/txwrapmock module.  For integration-style tests
+// against an in-memory SQLite DB, see the txwraptest/txwrapsqlite module.
+package txwraptest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sawka/txwrap"
+)
+
+// A query recorded by FakeTx
+type Query struct {
+	Method string
+	Query  string
+	Args   []interface{}
+}
+
+type response struct {
+	value        interface{}
+	err          error
+	rowsAffected int64
+	lastInsertId int64
+}
+
+// Fake implementation of txwrap.Tx.  Canned responses are registered per query
+// string (compared after trimming whitespace) and are consumed in order.  Once all
+// responses for a query are consumed, reads return no rows and writes affect 0 rows.
+// Like TxWrap, once Err is set all future calls are skipped (but still recorded).
+type FakeTx struct {
+	Err     error
+	Queries []Query
+
+	ctx       context.Context
+	responses map[string][]response
+}
+
+var _ txwrap.Tx = (*FakeTx)(nil)
+
+func NewFakeTx() *FakeTx {
+	return &FakeTx{ctx: context.Background(), responses: make(map[string][]response)}
+}
+
+func normalizeQuery(query string) string {
+	return strings.TrimSpace(query)
+}
+
+func (f *FakeTx) addResponse(query string, resp response) {
+	key := normalizeQuery(query)
+	f.responses[key] = append(f.responses[key], resp)
+}
+
+// Adds a result for 'query'.  For Get (and the typed getters) 'value' is a single
+// row (a scalar, struct, or map[string]interface{} for GetMap).  For Select it
+// should be a slice (a []map[string]interface{} for SelectMaps).
+func (f *FakeTx) AddResult(query string, value interface{}) {
+	f.addResponse(query, response{value: value})
+}
+
+// Adds a result for an Exec/NamedExec of 'query'
+func (f *FakeTx) AddExecResult(query string, rowsAffected int64, lastInsertId int64) {
+	f.addResponse(query, response{rowsAffected: rowsAffected, lastInsertId: lastInsertId})
+}
+
+// Makes the next call to 'query' fail with 'err' (setting f.Err)
+func (f *FakeTx) AddError(query string, err error) {
+	f.addResponse(query, response{err: err})
+}
+
+// Returns all recorded queries matching 'query'
+func (f *FakeTx) QueriesFor(query string) []Query {
+	var rtn []Query
+	key := normalizeQuery(query)
+	for _, q := range f.Queries {
+		if normalizeQuery(q.Query) == key {
+			rtn = append(rtn, q)
+		}
+	}
+	return rtn
+}
+
+// records the query and pops the next response.  returns false if the call
+// should return no result (prior error, or this call failed)
+func (f *FakeTx) run(method string, query string, args []interface{}) (*response, bool) {
+	f.Queries = append(f.Queries, Query{Method: method, Query: query, Args: args})
+	if f.Err != nil {
+		return nil, false
+	}
+	key := normalizeQuery(query)
+	resps := f.responses[key]
+	if len(resps) == 0 {
+		return nil, true
+	}
+	resp := resps[0]
+	f.responses[key] = resps[1:]
+	if resp.err != nil {
+		f.Err = resp.err
+		return nil, false
+	}
+	return &resp, true
+}
+
+func (f *FakeTx) Context() context.Context {
+	return f.ctx
+}
+
+// Sets the Context returned from Context()
+func (f *FakeTx) SetContext(ctx context.Context) {
+	f.ctx = ctx
+}
+
+type fakeResult struct {
+	rowsAffected int64
+	lastInsertId int64
+	err          error
+}
+
+func (r fakeResult) LastInsertId() (int64, error) {
+	return r.lastInsertId, r.err
+}
+
+func (r fakeResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, r.err
+}
+
+func (f *FakeTx) exec(method string, query string, args []interface{}) sql.Result {
+	resp, ok := f.run(method, query, args)
+	if !ok {
+		return fakeResult{err: f.Err}
+	}
+	if resp == nil {
+		return fakeResult{}
+	}
+	return fakeResult{rowsAffected: resp.rowsAffected, lastInsertId: resp.lastInsertId}
+}
+
+func (f *FakeTx) NamedExec(query string, arg interface{}) sql.Result {
+	return f.exec("NamedExec", query, []interface{}{arg})
+}
+
+func (f *FakeTx) Exec(query string, args ...interface{}) sql.Result {
+	return f.exec("Exec", query, args)
+}
+
+func (f *FakeTx) ExecMany(statements []string) {
+	for _, stmt := range statements {
+		if f.Err != nil {
+			return
+		}
+		f.Exec(stmt)
+	}
+}
+
+func (f *FakeTx) ExecAffected(query string, args ...interface{}) int64 {
+	numRows, _ := f.exec("ExecAffected", query, args).RowsAffected()
+	return numRows
+}
+
+func (f *FakeTx) ExecInsertId(query string, args ...interface{}) int64 {
+	insertId, _ := f.exec("ExecInsertId", query, args).LastInsertId()
+	return insertId
+}
+
+func (f *FakeTx) ExecExpect(expected int64, query string, args ...interface{}) bool {
+	numRows, _ := f.exec("ExecExpect", query, args).RowsAffected()
+	if f.Err != nil {
+		return false
+	}
+	if numRows != expected {
+		f.Err = &txwrap.AffectedRowsError{Query: query, Expected: expected, Actual: numRows}
+		return false
+	}
+	return true
+}
+
+func (f *FakeTx) ExecIn(query string, args ...interface{}) sql.Result {
+	return f.exec("ExecIn", query, args)
+}
+
+// assigns a canned value to dest (a pointer).  handles dest being a pointer
+// to a pointer of the value's type (e.g. **string for NULL-able scans).
+func (f *FakeTx) assign(dest interface{}, value interface{}) bool {
+	destVal := reflect.ValueOf(dest)
+	if destVal.Kind() != reflect.Pointer || destVal.IsNil() {
+		f.Err = fmt.Errorf("txwraptest: dest must be a non-nil pointer, got %T", dest)
+		return false
+	}
+	target := destVal.Elem()
+	if value == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return true
+	}
+	val := reflect.ValueOf(value)
+	switch {
+	case val.Type().AssignableTo(target.Type()):
+		target.Set(val)
+	case target.Kind() == reflect.Pointer && val.Type().AssignableTo(target.Type().Elem()):
+		ptr := reflect.New(target.Type().Elem())
+		ptr.Elem().Set(val)
+		target.Set(ptr)
+	case isNumeric(val.Type()) && isNumeric(target.Type()):
+		target.Set(val.Convert(target.Type()))
+	case target.Kind() == reflect.Pointer && isNumeric(val.Type()) && isNumeric(target.Type().Elem()):
+		ptr := reflect.New(target.Type().Elem())
+		ptr.Elem().Set(val.Convert(target.Type().Elem()))
+		target.Set(ptr)
+	default:
+		f.Err = fmt.Errorf("txwraptest: cannot assign result of type %T to %T", value, dest)
+		return false
+	}
+	return true
+}
+
+// canned numeric values (e.g. an untyped 5) can be scanned into any numeric type
+func isNumeric(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func (f *FakeTx) get(method string, dest interface{}, query string, args []interface{}) bool {
+	resp, ok := f.run(method, query, args)
+	if !ok || resp == nil {
+		return false
+	}
+	return f.assign(dest, resp.value)
+}
+
+func (f *FakeTx) selectRows(method string, dest interface{}, query string, args []interface{}) {
+	resp, ok := f.run(method, query, args)
+	if !ok || resp == nil {
+		return
+	}
+	f.assign(dest, resp.value)
+}
+
+func (f *FakeTx) Get(dest interface{}, query string, args ...interface{}) bool {
+	return f.get("Get", dest, query, args)
+}
+
+func (f *FakeTx) GetRequired(dest interface{}, query string, args ...interface{}) bool {
+	if f.Err != nil {
+		f.run("GetRequired", query, args)
+		return false
+	}
+	found := f.get("GetRequired", dest, query, args)
+	if !found && f.Err == nil {
+		f.Err = fmt.Errorf("no rows returned for required query %q: %w", query, sql.ErrNoRows)
+	}
+	return found
+}
+
+func (f *FakeTx) GetIn(dest interface{}, query string, args ...interface{}) bool {
+	return f.get("GetIn", dest, query, args)
+}
+
+func (f *FakeTx) NamedGet(dest interface{}, query string, arg interface{}) bool {
+	return f.get("NamedGet", dest, query, []interface{}{arg})
+}
+
+func (f *FakeTx) Select(dest interface{}, query string, args ...interface{}) {
+	f.selectRows("Select", dest, query, args)
+}
+
+func (f *FakeTx) SelectIn(dest interface{}, query string, args ...interface{}) {
+	f.selectRows("SelectIn", dest, query, args)
+}
+
+func (f *FakeTx) NamedSelect(dest interface{}, query string, arg interface{}) {
+	f.selectRows("NamedSelect", dest, query, []interface{}{arg})
+}
+
+func (f *FakeTx) Exists(query string, args ...interface{}) bool {
+	resp, ok := f.run("Exists", query, args)
+	return ok && resp != nil
+}
+
+func (f *FakeTx) NamedExists(query string, arg interface{}) bool {
+	resp, ok := f.run("NamedExists", query, []interface{}{arg})
+	return ok && resp != nil
+}
+
+func getOk[RT any](f *FakeTx, query string, args []interface{}) (RT, bool) {
+	var rtn *RT
+	f.Get(&rtn, query, args...)
+	if rtn == nil {
+		var zero RT
+		return zero, false
+	}
+	return *rtn, true
+}
+
+func (f *FakeTx) GetString(query string, args ...interface{}) string {
+	rtn, _ := getOk[string](f, query, args)
+	return rtn
+}
+
+func (f *FakeTx) GetStringOk(query string, args ...interface{}) (string, bool) {
+	return getOk[string](f, query, args)
+}
+
+func (f *FakeTx) GetFloat64(query string, args ...interface{}) float64 {
+	rtn, _ := getOk[float64](f, query, args)
+	return rtn
+}
+
+func (f *FakeTx) GetFloat64Ok(query string, args ...interface{}) (float64, bool) {
+	return getOk[float64](f, query, args)
+}
+
+func (f *FakeTx) GetByteArr(query string, args ...interface{}) []byte {
+	rtn, _ := getOk[[]byte](f, query, args)
+	return rtn
+}
+
+func (f *FakeTx) GetBytesOk(query string, args ...interface{}) ([]byte, bool) {
+	var rtn []byte
+	if !f.Get(&rtn, query, args...) {
+		return nil, false
+	}
+	return rtn, true
+}
+
+func (f *FakeTx) GetBool(query string, args ...interface{}) bool {
+	rtn, _ := getOk[bool](f, query, args)
+	return rtn
+}
+
+func (f *FakeTx) GetBoolOk(query string, args ...interface{}) (bool, bool) {
+	return getOk[bool](f, query, args)
+}
+
+func (f *FakeTx) GetTime(query string, args ...interface{}) time.Time {
+	rtn, _ := getOk[time.Time](f, query, args)
+	return rtn
+}
+
+func (f *FakeTx) GetNullTime(query string, args ...interface{}) (time.Time, bool) {
+	return getOk[time.Time](f, query, args)
+}
+
+// The canned result is returned as-is (it is not normalized like TxWrap.GetUUID)
+func (f *FakeTx) GetUUID(query string, args ...interface{}) string {
+	rtn, _ := getOk[string](f, query, args)
+	return rtn
+}
+
+func (f *FakeTx) GetUUIDOk(query string, args ...interface{}) (string, bool) {
+	return getOk[string](f, query, args)
+}
+
+func (f *FakeTx) GetInt(query string, args ...interface{}) int {
+	rtn, _ := getOk[int](f, query, args)
+	return rtn
+}
+
+func (f *FakeTx) GetIntOk(query string, args ...interface{}) (int, bool) {
+	return getOk[int](f, query, args)
+}
+
+func (f *FakeTx) GetInt64(query string, args ...interface{}) int64 {
+	rtn, _ := getOk[int64](f, query, args)
+	return rtn
+}
+
+func (f *FakeTx) GetInt64Ok(query string, args ...interface{}) (int64, bool) {
+	return getOk[int64](f, query, args)
+}
+
+// Same as TxWrap.GetCount, no result (or a nil result) sets f.Err
+func (f *FakeTx) GetCount(query string, args ...interface{}) int64 {
+	if f.Err != nil {
+		f.run("GetCount", query, args)
+		return 0
+	}
+	rtn, ok := getOk[int64](f, query, args)
+	if !ok && f.Err == nil {
+		f.Err = fmt.Errorf("no count returned for query %q: %w", query, sql.ErrNoRows)
+	}
+	return rtn
+}
+
+func (f *FakeTx) SelectStrings(query string, args ...interface{}) []string {
+	var rtn []string
+	f.Select(&rtn, query, args...)
+	return rtn
+}
+
+func (f *FakeTx) SelectInt64s(query string, args ...interface{}) []int64 {
+	var rtn []int64
+	f.Select(&rtn, query, args...)
+	return rtn
+}
+
+func (f *FakeTx) SelectInts(query string, args ...interface{}) []int {
+	var rtn []int
+	f.Select(&rtn, query, args...)
+	return rtn
+}
+
+func (f *FakeTx) SelectBools(query string, args ...interface{}) []bool {
+	var rtn []bool
+	f.Select(&rtn, query, args...)
+	return rtn
+}
+
+func (f *FakeTx) SelectTimes(query string, args ...interface{}) []time.Time {
+	var rtn []time.Time
+	f.Select(&rtn, query, args...)
+	return rtn
+}
+
+func (f *FakeTx) SelectMaps(query string, args ...interface{}) []map[string]interface{} {
+	var rtn []map[string]interface{}
+	f.selectRows("SelectMaps", &rtn, query, args)
+	return rtn
+}
+
+func (f *FakeTx) GetMap(query string, args ...interface{}) map[string]interface{} {
+	rtn, _ := f.GetMapOk(query, args...)
+	return rtn
+}
+
+func (f *FakeTx) GetMapOk(query string, args ...interface{}) (map[string]interface{}, bool) {
+	var rtn map[string]interface{}
+	if !f.get("GetMap", &rtn, query, args) {
+		return nil, false
+	}
+	return rtn, true
+}
+
+// Columns are returned in sorted order (canned maps have no column order)
+func (f *FakeTx) SelectOrderedMaps(query string, args ...interface{}) ([]string, []map[string]interface{}) {
+	var rtn []map[string]interface{}
+	f.selectRows("SelectOrderedMaps", &rtn, query, args)
+	if len(rtn) == 0 {
+		return nil, nil
+	}
+	var cols []string
+	for col := range rtn[0] {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	return cols, rtn
+}
+
+func (f *FakeTx) Run(fn func() error) {
+	if f.Err != nil {
+		return
+	}
+	err := fn()
+	if err != nil {
+		f.Err = err
+	}
+}
+
+func (f *FakeTx) SetErr(err error) {
+	if f.Err == nil {
+		f.Err = err
+	}
+}
@@ -0,0 +1,11 @@
+module github.com/sawka/txwrap/txwraptest/txwrapmock
+
+go 1.22
+
+require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/jmoiron/sqlx v1.4.0
+	github.com/sawka/txwrap v0.0.0
+)
+
+replace github.com/sawka/txwrap => ../../
@@ -0,0 +1,124 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+// go-sqlmock helpers for testing code that uses txwrap.WithTx.  Use this (instead
+// of txwraptest.FakeTx) to test the transaction itself: that it begins, commits, or
+// rolls back, and the exact SQL that runs.  This is a separate module so txwrap
+// does not depend on go-sqlmock.
+//
+// Usage:
+//
+//	m := txwrapmock.New(t, "postgres")
+//	m.ExpectTx(func() {
+//	    m.ExpectExec(`UPDATE users SET name = $1 WHERE id = $2`).
+//	        WithArgs("mike", 5).WillReturnResult(sqlmock.NewResult(0, 1))
+//	})
+//	err := txwrap.WithTx(ctx, m.DB, fn, m.TxOption())
+//
+// Expectations are checked when the test finishes.
+package txwrapmock
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/sawka/txwrap"
+)
+
+// Wraps a go-sqlmock connection.  Sqlmock is embedded, so all of the sqlmock
+// Expect methods are available on Mock.  Queries are matched with
+// sqlmock.QueryMatcherEqual (exact text, ignoring whitespace), not regexps.
+type Mock struct {
+	sqlmock.Sqlmock
+	DB *sqlx.DB
+
+	lock    sync.Mutex
+	queries []txwrap.QueryInfo
+}
+
+// Creates a Mock.  'driverName' sets the bindvar type used by sqlx (e.g. "postgres"
+// for $1 queries, "mysql" or "sqlite3" for ?).  When the test finishes the DB is
+// closed and the test fails if any expectations were not met.
+func New(t testing.TB, driverName string) *Mock {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("txwrapmock, cannot create sqlmock: %v", err)
+	}
+	m := &Mock{Sqlmock: mock, DB: sqlx.NewDb(db, driverName)}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("txwrapmock, %v", err)
+		}
+		m.DB.Close()
+	})
+	return m
+}
+
+// Expects a transaction that commits: Begin, the expectations set by 'fn', Commit.
+func (m *Mock) ExpectTx(fn func()) {
+	m.ExpectBegin()
+	fn()
+	m.ExpectCommit()
+}
+
+// Expects a transaction that rolls back: Begin, the expectations set by 'fn',
+// Rollback.  WithTx rolls back if 'fn' returns an error or a DB call fails (set
+// WillReturnError on the failing expectation in 'fn').  Note that WithTx does not
+// run any more queries through the TxWrap after the first DB error.
+func (m *Mock) ExpectTxRollback(fn func()) {
+	m.ExpectBegin()
+	fn()
+	m.ExpectRollback()
+}
+
+// Expects a transaction whose Commit fails with 'err' (WithTx returns an error
+// wrapping txwrap.ErrCommitFailed).
+func (m *Mock) ExpectTxCommitError(err error, fn func()) {
+	m.ExpectBegin()
+	fn()
+	m.ExpectCommit().WillReturnError(err)
+}
+
+// Returns a TxOption that records every DB call made through the TxWrap (see Queries)
+func (m *Mock) TxOption() txwrap.TxOption {
+	return txwrap.WithQueryHook(func(ctx context.Context, info txwrap.QueryInfo) {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		m.queries = append(m.queries, info)
+	})
+}
+
+// Returns the DB calls recorded by TxOption (in order)
+func (m *Mock) Queries() []txwrap.QueryInfo {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]txwrap.QueryInfo(nil), m.queries...)
+}
+
+// Fails the test unless the recorded queries (see TxOption) are exactly 'queries'
+// (compared as strings, in order).
+func (m *Mock) AssertQueries(t testing.TB, queries ...string) {
+	t.Helper()
+	recorded := m.Queries()
+	if len(recorded) != len(queries) {
+		t.Errorf("txwrapmock, expected %d queries, got %d: %v", len(queries), len(recorded), queryStrs(recorded))
+		return
+	}
+	for idx, info := range recorded {
+		if info.Query != queries[idx] {
+			t.Errorf("txwrapmock, query #%d, expected %q, got %q", idx, queries[idx], info.Query)
+		}
+	}
+}
+
+func queryStrs(infos []txwrap.QueryInfo) []string {
+	rtn := make([]string, len(infos))
+	for idx, info := range infos {
+		rtn[idx] = info.Query
+	}
+	return rtn
+}
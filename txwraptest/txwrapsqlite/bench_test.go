@@ -0,0 +1,89 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrapsqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sawka/txwrap"
+	"github.com/sawka/txwrap/txwraptest/txwrapsqlite"
+)
+
+type benchRow struct {
+	Id   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+func newBenchHarness(b *testing.B) *txwrapsqlite.Harness {
+	h := txwrapsqlite.New(b)
+	h.RunTx(b, func(tx *txwrap.TxWrap) {
+		tx.Exec(`CREATE TABLE bench (id integer PRIMARY KEY, name text)`)
+		for i := 0; i < 10; i++ {
+			tx.Exec(`INSERT INTO bench (name) VALUES (?)`, "name")
+		}
+	})
+	return h
+}
+
+// runs 'fn' b.N times inside of a single transaction (which is rolled back)
+func runBenchTx(b *testing.B, fn func(tx *txwrap.TxWrap)) {
+	h := newBenchHarness(b)
+	b.ReportAllocs()
+	h.RunTxTest(b, func(tx *txwrap.TxWrap) {
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			fn(tx)
+		}
+		b.StopTimer()
+	})
+}
+
+func BenchmarkContext(b *testing.B) {
+	runBenchTx(b, func(tx *txwrap.TxWrap) {
+		_ = tx.Context()
+	})
+}
+
+func BenchmarkNestedWithTx(b *testing.B) {
+	runBenchTx(b, func(tx *txwrap.TxWrap) {
+		txwrap.WithTx(tx.Context(), nil, func(tx *txwrap.TxWrap) error {
+			_ = tx.Context()
+			return nil
+		})
+	})
+}
+
+func BenchmarkExec(b *testing.B) {
+	runBenchTx(b, func(tx *txwrap.TxWrap) {
+		tx.Exec(`UPDATE bench SET name = ? WHERE id = ?`, "updated", 1)
+	})
+}
+
+func BenchmarkGet(b *testing.B) {
+	runBenchTx(b, func(tx *txwrap.TxWrap) {
+		var row benchRow
+		tx.Get(&row, `SELECT id, name FROM bench WHERE id = ?`, 1)
+	})
+}
+
+func BenchmarkSelect(b *testing.B) {
+	runBenchTx(b, func(tx *txwrap.TxWrap) {
+		var rows []benchRow
+		tx.Select(&rows, `SELECT id, name FROM bench`)
+	})
+}
+
+func BenchmarkWithTx(b *testing.B) {
+	h := newBenchHarness(b)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		txwrap.WithTx(ctx, h.DB, func(tx *txwrap.TxWrap) error {
+			tx.GetInt(`SELECT count(*) FROM bench`)
+			return nil
+		})
+	}
+}
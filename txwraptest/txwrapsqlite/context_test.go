@@ -0,0 +1,63 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrapsqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sawka/txwrap"
+	"github.com/sawka/txwrap/txwraptest/txwrapsqlite"
+)
+
+type requestIdKey struct{}
+type userIdKey struct{}
+
+type hookCtxVals struct {
+	query     string
+	requestId interface{}
+	userId    interface{}
+}
+
+func TestContextValuesInQueryHooks(t *testing.T) {
+	h := txwrapsqlite.New(t)
+	var seen []hookCtxVals
+	hook := txwrap.WithQueryHook(func(ctx context.Context, info txwrap.QueryInfo) {
+		seen = append(seen, hookCtxVals{
+			query:     info.Query,
+			requestId: ctx.Value(requestIdKey{}),
+			userId:    ctx.Value(userIdKey{}),
+		})
+	})
+	ctx := context.WithValue(context.Background(), requestIdKey{}, "req-1")
+	err := txwrap.WithTx(ctx, h.DB, func(tx *txwrap.TxWrap) error {
+		tx.GetInt(`SELECT 1`)
+		nestedCtx := context.WithValue(tx.Context(), userIdKey{}, "user-1")
+		err := txwrap.WithTx(nestedCtx, h.DB, func(tx *txwrap.TxWrap) error {
+			tx.GetInt(`SELECT 2`)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		tx.GetInt(`SELECT 3`)
+		return nil
+	}, hook)
+	if err != nil {
+		t.Fatalf("WithTx error: %v", err)
+	}
+	expected := []hookCtxVals{
+		{query: `SELECT 1`, requestId: "req-1"},
+		{query: `SELECT 2`, requestId: "req-1", userId: "user-1"},
+		{query: `SELECT 3`, requestId: "req-1"},
+	}
+	if len(seen) != len(expected) {
+		t.Fatalf("expected %d hook calls, got %d: %v", len(expected), len(seen), seen)
+	}
+	for idx, exp := range expected {
+		if seen[idx] != exp {
+			t.Errorf("hook call %d: expected %v, got %v", idx, exp, seen[idx])
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrapsqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/sawka/txwrap"
+	"github.com/sawka/txwrap/txwraptest/txwrapsqlite"
+)
+
+func newScalarHarness(t *testing.T) *txwrapsqlite.Harness {
+	h := txwrapsqlite.New(t)
+	h.RunTx(t, func(tx *txwrap.TxWrap) {
+		tx.Exec(`CREATE TABLE vals (id integer PRIMARY KEY, num integer, str text)`)
+		tx.Exec(`INSERT INTO vals VALUES (1, 42, 'hello'), (2, 0, ''), (3, NULL, NULL)`)
+	})
+	return h
+}
+
+func TestGetScalarValue(t *testing.T) {
+	h := newScalarHarness(t)
+	h.RunTxTest(t, func(tx *txwrap.TxWrap) {
+		num, ok := txwrap.GetScalar[int64](tx, `SELECT num FROM vals WHERE id = ?`, 1)
+		if !ok || num != 42 {
+			t.Errorf("value: expected (42, true), got (%v, %v)", num, ok)
+		}
+	})
+}
+
+func TestGetScalarZero(t *testing.T) {
+	h := newScalarHarness(t)
+	h.RunTxTest(t, func(tx *txwrap.TxWrap) {
+		num, ok := txwrap.GetScalar[int64](tx, `SELECT num FROM vals WHERE id = ?`, 2)
+		if !ok || num != 0 {
+			t.Errorf("zero int: expected (0, true), got (%v, %v)", num, ok)
+		}
+		str, ok := txwrap.GetScalar[string](tx, `SELECT str FROM vals WHERE id = ?`, 2)
+		if !ok || str != "" {
+			t.Errorf("zero string: expected (\"\", true), got (%q, %v)", str, ok)
+		}
+	})
+}
+
+func TestGetScalarNull(t *testing.T) {
+	h := newScalarHarness(t)
+	h.RunTxTest(t, func(tx *txwrap.TxWrap) {
+		num, ok := txwrap.GetScalar[int64](tx, `SELECT num FROM vals WHERE id = ?`, 3)
+		if ok || num != 0 {
+			t.Errorf("NULL: expected (0, false), got (%v, %v)", num, ok)
+		}
+		if tx.Err != nil {
+			t.Errorf("NULL should not set tx.Err, got %v", tx.Err)
+		}
+	})
+}
+
+func TestGetScalarNoRows(t *testing.T) {
+	h := newScalarHarness(t)
+	h.RunTxTest(t, func(tx *txwrap.TxWrap) {
+		num, ok := txwrap.GetScalar[int64](tx, `SELECT num FROM vals WHERE id = ?`, 4)
+		if ok || num != 0 {
+			t.Errorf("no rows: expected (0, false), got (%v, %v)", num, ok)
+		}
+		if tx.Err != nil {
+			t.Errorf("no rows should not set tx.Err, got %v", tx.Err)
+		}
+	})
+}
+
+func TestGetScalarScanner(t *testing.T) {
+	h := newScalarHarness(t)
+	h.RunTxTest(t, func(tx *txwrap.TxWrap) {
+		str, ok := txwrap.GetScalar[sql.NullString](tx, `SELECT str FROM vals WHERE id = ?`, 1)
+		if !ok || !str.Valid || str.String != "hello" {
+			t.Errorf("scanner: expected ({hello true}, true), got (%v, %v)", str, ok)
+		}
+	})
+}
+
+func TestGetScalarError(t *testing.T) {
+	h := newScalarHarness(t)
+	err := txwrap.WithTx(context.Background(), h.DB, func(tx *txwrap.TxWrap) error {
+		num, ok := txwrap.GetScalar[int64](tx, `SELECT num FROM no_such_table`)
+		if ok || num != 0 {
+			t.Errorf("error: expected (0, false), got (%v, %v)", num, ok)
+		}
+		if tx.Err == nil {
+			t.Errorf("error: expected tx.Err to be set")
+		}
+		return nil
+	})
+	if err == nil {
+		t.Errorf("error: expected WithTx to return the query error")
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrapsqlite_test
+
+import (
+	"testing"
+
+	"github.com/sawka/txwrap"
+	"github.com/sawka/txwrap/txwraptest/txwrapsqlite"
+)
+
+func newBlobHarness(t *testing.T) *txwrapsqlite.Harness {
+	h := txwrapsqlite.New(t)
+	h.RunTx(t, func(tx *txwrap.TxWrap) {
+		tx.Exec(`CREATE TABLE blobs (id integer PRIMARY KEY, data blob)`)
+		tx.Exec(`INSERT INTO blobs VALUES (1, x'0102'), (2, x''), (3, NULL)`)
+	})
+	return h
+}
+
+func TestGetBytesOk(t *testing.T) {
+	h := newBlobHarness(t)
+	h.RunTxTest(t, func(tx *txwrap.TxWrap) {
+		data, ok := tx.GetBytesOk(`SELECT data FROM blobs WHERE id = ?`, 1)
+		if !ok || string(data) != "\x01\x02" {
+			t.Errorf("value: got (%v, %v)", data, ok)
+		}
+	})
+}
+
+func TestGetBytesOkEmpty(t *testing.T) {
+	h := newBlobHarness(t)
+	h.RunTxTest(t, func(tx *txwrap.TxWrap) {
+		data, ok := tx.GetBytesOk(`SELECT data FROM blobs WHERE id = ?`, 2)
+		if !ok || data == nil || len(data) != 0 {
+			t.Errorf("empty: expected ([]byte{}, true), got (%#v, %v)", data, ok)
+		}
+	})
+}
+
+func TestGetBytesOkNull(t *testing.T) {
+	h := newBlobHarness(t)
+	h.RunTxTest(t, func(tx *txwrap.TxWrap) {
+		data, ok := tx.GetBytesOk(`SELECT data FROM blobs WHERE id = ?`, 3)
+		if !ok || data != nil {
+			t.Errorf("NULL: expected (nil, true), got (%#v, %v)", data, ok)
+		}
+	})
+}
+
+func TestGetBytesOkNoRows(t *testing.T) {
+	h := newBlobHarness(t)
+	h.RunTxTest(t, func(tx *txwrap.TxWrap) {
+		data, ok := tx.GetBytesOk(`SELECT data FROM blobs WHERE id = ?`, 4)
+		if ok || data != nil {
+			t.Errorf("no rows: expected (nil, false), got (%#v, %v)", data, ok)
+		}
+	})
+}
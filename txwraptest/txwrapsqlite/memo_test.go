@@ -0,0 +1,59 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrapsqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sawka/txwrap"
+	"github.com/sawka/txwrap/txwraptest/txwrapsqlite"
+)
+
+func newMemoHarness(t *testing.T) *txwrapsqlite.Harness {
+	h := txwrapsqlite.New(t)
+	h.RunTx(t, func(tx *txwrap.TxWrap) {
+		tx.Exec(`CREATE TABLE users (id integer PRIMARY KEY, name text, token text)`)
+		tx.Exec(`INSERT INTO users VALUES (1, 'mike', 'token-a'), (2, 'sam', 'token-b')`)
+	})
+	return h
+}
+
+func TestMemoizeRedactedArgs(t *testing.T) {
+	h := newMemoHarness(t)
+	h.RunTxTest(t, func(tx *txwrap.TxWrap) {
+		query := `SELECT name FROM users WHERE token = ?`
+		name1 := tx.GetString(query, txwrap.Redact("token-a"))
+		name2 := tx.GetString(query, txwrap.Redact("token-b"))
+		if name1 != "mike" || name2 != "sam" {
+			t.Errorf("expected mike, sam, got %q, %q", name1, name2)
+		}
+	}, txwrap.WithMemoize())
+}
+
+func TestMemoizePointerArgs(t *testing.T) {
+	h := newMemoHarness(t)
+	var numQueries int
+	countQueries := txwrap.WithQueryHook(func(ctx context.Context, info txwrap.QueryInfo) {
+		numQueries++
+	})
+	h.RunTxTest(t, func(tx *txwrap.TxWrap) {
+		query := `SELECT name FROM users WHERE id = ?`
+		id := new(int64)
+		*id = 1
+		name1 := tx.GetString(query, id)
+		*id = 2
+		name2 := tx.GetString(query, id)
+		if name1 != "mike" || name2 != "sam" {
+			t.Errorf("reused pointer: expected mike, sam, got %q, %q", name1, name2)
+		}
+		otherId := int64(2)
+		if name := tx.GetString(query, &otherId); name != "sam" {
+			t.Errorf("equal values behind different pointers: expected sam, got %q", name)
+		}
+		if numQueries != 2 {
+			t.Errorf("expected the last Get to be memoized (2 queries), got %d queries", numQueries)
+		}
+	}, txwrap.WithMemoize(), countQueries)
+}
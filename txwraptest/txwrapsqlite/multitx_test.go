@@ -0,0 +1,216 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrapsqlite_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sawka/txwrap"
+	"github.com/sawka/txwrap/txwraptest/txwrapsqlite"
+)
+
+// two in-memory DBs, each with an items table.  db #1 also has a deferred foreign
+// key (checked at COMMIT) so a commit can be made to fail after db #0 commits.
+func newMultiDBs(t *testing.T) []*sqlx.DB {
+	h0 := txwrapsqlite.New(t)
+	h1 := txwrapsqlite.New(t)
+	h0.RunTx(t, func(tx *txwrap.TxWrap) {
+		tx.Exec(`CREATE TABLE items (id integer PRIMARY KEY)`)
+	})
+	h1.RunTx(t, func(tx *txwrap.TxWrap) {
+		tx.Exec(`CREATE TABLE items (id integer PRIMARY KEY)`)
+		tx.Exec(`CREATE TABLE item_refs (item_id integer REFERENCES items(id) DEFERRABLE INITIALLY DEFERRED)`)
+	})
+	return []*sqlx.DB{h0.DB, h1.DB}
+}
+
+func countItems(t *testing.T, db *sqlx.DB) int {
+	t.Helper()
+	var count int
+	if err := db.Get(&count, `SELECT count(*) FROM items`); err != nil {
+		t.Fatalf("count items: %v", err)
+	}
+	return count
+}
+
+// records the OnCommit and OnRollback callbacks run for each database
+type multiHooks struct {
+	committed  []int
+	rolledBack map[int]error
+}
+
+func (mh *multiHooks) register(txs []*txwrap.TxWrap) {
+	for idx, tx := range txs {
+		tx.OnCommit(func() error {
+			mh.committed = append(mh.committed, idx)
+			return nil
+		})
+		tx.OnRollback(func(err error) {
+			mh.rolledBack[idx] = err
+		})
+	}
+}
+
+func newMultiHooks() *multiHooks {
+	return &multiHooks{rolledBack: make(map[int]error)}
+}
+
+func TestMultiTxCommit(t *testing.T) {
+	dbs := newMultiDBs(t)
+	mh := newMultiHooks()
+	err := txwrap.WithMultiTx(context.Background(), dbs, func(txs []*txwrap.TxWrap) error {
+		mh.register(txs)
+		txs[0].Exec(`INSERT INTO items VALUES (1)`)
+		txs[1].Exec(`INSERT INTO items VALUES (1)`)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithMultiTx error: %v", err)
+	}
+	if countItems(t, dbs[0]) != 1 || countItems(t, dbs[1]) != 1 {
+		t.Errorf("expected both databases to commit")
+	}
+	if !reflect.DeepEqual(mh.committed, []int{0, 1}) || len(mh.rolledBack) != 0 {
+		t.Errorf("expected OnCommit for both databases, got committed=%v rolledBack=%v", mh.committed, mh.rolledBack)
+	}
+}
+
+func TestMultiTxRollbackOnError(t *testing.T) {
+	dbs := newMultiDBs(t)
+	mh := newMultiHooks()
+	fnErr := errors.New("fn failed")
+	err := txwrap.WithMultiTx(context.Background(), dbs, func(txs []*txwrap.TxWrap) error {
+		mh.register(txs)
+		txs[0].Exec(`INSERT INTO items VALUES (1)`)
+		txs[1].Exec(`INSERT INTO items VALUES (1)`)
+		return fnErr
+	})
+	if !errors.Is(err, fnErr) {
+		t.Fatalf("expected fnErr, got %v", err)
+	}
+	if countItems(t, dbs[0]) != 0 || countItems(t, dbs[1]) != 0 {
+		t.Errorf("expected both databases to roll back")
+	}
+	if len(mh.committed) != 0 || !errors.Is(mh.rolledBack[0], fnErr) || !errors.Is(mh.rolledBack[1], fnErr) {
+		t.Errorf("expected OnRollback(fnErr) for both databases, got committed=%v rolledBack=%v", mh.committed, mh.rolledBack)
+	}
+}
+
+func TestMultiTxRollbackOnDBError(t *testing.T) {
+	dbs := newMultiDBs(t)
+	err := txwrap.WithMultiTx(context.Background(), dbs, func(txs []*txwrap.TxWrap) error {
+		txs[0].Exec(`INSERT INTO items VALUES (1)`)
+		txs[1].Exec(`INSERT INTO no_such_table VALUES (1)`)
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("expected an error from the failed insert")
+	}
+	if countItems(t, dbs[0]) != 0 {
+		t.Errorf("expected db #0 to roll back")
+	}
+}
+
+func TestMultiTxPartialCommit(t *testing.T) {
+	dbs := newMultiDBs(t)
+	mh := newMultiHooks()
+	err := txwrap.WithMultiTx(context.Background(), dbs, func(txs []*txwrap.TxWrap) error {
+		mh.register(txs)
+		txs[0].Exec(`INSERT INTO items VALUES (1)`)
+		// violates the deferred foreign key, fails at COMMIT
+		txs[1].Exec(`INSERT INTO item_refs VALUES (99)`)
+		return nil
+	})
+	var pcErr *txwrap.PartialCommitError
+	if !errors.As(err, &pcErr) {
+		t.Fatalf("expected a PartialCommitError, got %v", err)
+	}
+	if !reflect.DeepEqual(pcErr.Committed, []int{0}) || pcErr.Failed != 1 {
+		t.Errorf("expected Committed=[0] Failed=1, got Committed=%v Failed=%d", pcErr.Committed, pcErr.Failed)
+	}
+	if countItems(t, dbs[0]) != 1 {
+		t.Errorf("expected db #0 to commit")
+	}
+	if len(mh.committed) != 0 {
+		t.Errorf("OnCommit should not run on a partial commit, got %v", mh.committed)
+	}
+	if _, ok := mh.rolledBack[0]; ok {
+		t.Errorf("OnRollback should not run for db #0 (committed)")
+	}
+	if !errors.Is(mh.rolledBack[1], err) {
+		t.Errorf("expected OnRollback for db #1, got %v", mh.rolledBack[1])
+	}
+}
+
+func TestMultiTxRollbackOnly(t *testing.T) {
+	dbs := newMultiDBs(t)
+	mh := newMultiHooks()
+	err := txwrap.WithMultiTx(context.Background(), dbs, func(txs []*txwrap.TxWrap) error {
+		mh.register(txs)
+		txs[0].Exec(`INSERT INTO items VALUES (1)`)
+		txs[1].Exec(`INSERT INTO items VALUES (1)`)
+		if n := txs[0].GetInt(`SELECT count(*) FROM items`); n != 1 {
+			t.Errorf("expected the insert to run inside the transaction, got %d rows", n)
+		}
+		return nil
+	}, txwrap.WithRollbackOnly())
+	if err != nil {
+		t.Fatalf("WithMultiTx error: %v", err)
+	}
+	if countItems(t, dbs[0]) != 0 || countItems(t, dbs[1]) != 0 {
+		t.Errorf("expected both databases to roll back")
+	}
+	if len(mh.committed) != 0 || mh.rolledBack[0] != txwrap.ErrRollbackOnly || mh.rolledBack[1] != txwrap.ErrRollbackOnly {
+		t.Errorf("expected OnRollback(ErrRollbackOnly) for both databases, got committed=%v rolledBack=%v", mh.committed, mh.rolledBack)
+	}
+}
+
+func TestMultiTxDryRun(t *testing.T) {
+	dbs := newMultiDBs(t)
+	mh := newMultiHooks()
+	var reported []string
+	dryRun := txwrap.WithDryRun(func(ctx context.Context, query string, args []interface{}) {
+		reported = append(reported, query)
+	})
+	err := txwrap.WithMultiTx(context.Background(), dbs, func(txs []*txwrap.TxWrap) error {
+		mh.register(txs)
+		txs[0].Exec(`INSERT INTO items VALUES (1)`)
+		txs[1].Exec(`INSERT INTO items VALUES (2)`)
+		if n := txs[0].GetInt(`SELECT count(*) FROM items`); n != 0 {
+			t.Errorf("dry run should not run the insert, got %d rows", n)
+		}
+		return nil
+	}, dryRun)
+	if err != nil {
+		t.Fatalf("WithMultiTx error: %v", err)
+	}
+	expected := []string{`INSERT INTO items VALUES (1)`, `INSERT INTO items VALUES (2)`}
+	if !reflect.DeepEqual(reported, expected) {
+		t.Errorf("expected dry run to report %v, got %v", expected, reported)
+	}
+	if countItems(t, dbs[0]) != 0 || countItems(t, dbs[1]) != 0 {
+		t.Errorf("dry run should not change either database")
+	}
+	if len(mh.committed) != 0 || mh.rolledBack[0] != txwrap.ErrDryRun || mh.rolledBack[1] != txwrap.ErrDryRun {
+		t.Errorf("expected OnRollback(ErrDryRun) for both databases, got committed=%v rolledBack=%v", mh.committed, mh.rolledBack)
+	}
+}
+
+func TestMultiTxReadOnly(t *testing.T) {
+	dbs := newMultiDBs(t)
+	err := txwrap.WithMultiTx(context.Background(), dbs, func(txs []*txwrap.TxWrap) error {
+		txs[1].Exec(`INSERT INTO items VALUES (1)`)
+		return nil
+	}, txwrap.WithReadOnly())
+	if !errors.Is(err, txwrap.ErrReadOnlyTx) {
+		t.Fatalf("expected ErrReadOnlyTx, got %v", err)
+	}
+	if countItems(t, dbs[1]) != 0 {
+		t.Errorf("expected the insert not to run")
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrapsqlite_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sawka/txwrap"
+	"github.com/sawka/txwrap/txwraptest/txwrapsqlite"
+)
+
+// a slice-typed error (like validator.ValidationErrors), not comparable with ==
+type multiErr []error
+
+func (e multiErr) Error() string {
+	return "multiErr"
+}
+
+func TestWithSavepointUncomparableErr(t *testing.T) {
+	h := txwrapsqlite.New(t)
+	h.RunTxTest(t, func(tx *txwrap.TxWrap) {
+		tx.Exec(`CREATE TABLE items (id integer PRIMARY KEY)`)
+		err := txwrap.WithSavepoint(tx, func() error {
+			tx.Exec(`INSERT INTO items VALUES (1)`)
+			return multiErr{errors.New("bad item")}
+		})
+		var me multiErr
+		if !errors.As(err, &me) {
+			t.Fatalf("expected multiErr, got %v", err)
+		}
+		if tx.Err != nil {
+			t.Fatalf("tx.Err should be cleared after the savepoint rollback, got %v", tx.Err)
+		}
+		if n := tx.GetInt(`SELECT count(*) FROM items`); n != 0 {
+			t.Errorf("expected the insert to be rolled back, got %d rows", n)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrapsqlite_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/sawka/txwrap"
+	"github.com/sawka/txwrap/txwraptest/txwrapsqlite"
+)
+
+type mappedUser struct {
+	UserId   int64 `db:"id"`
+	UserName string
+	Email    string
+}
+
+// "UserName" -> "user_name"
+func snakeCase(name string) string {
+	var buf strings.Builder
+	for idx, r := range name {
+		if unicode.IsUpper(r) {
+			if idx > 0 {
+				buf.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+func newMapperHarness(t *testing.T) *txwrapsqlite.Harness {
+	h := txwrapsqlite.New(t)
+	h.DB.MapperFunc(snakeCase)
+	h.RunTx(t, func(tx *txwrap.TxWrap) {
+		tx.Exec(`CREATE TABLE users (id integer PRIMARY KEY, user_name text, email text)`)
+	})
+	return h
+}
+
+func TestStructFieldsUseDBMapper(t *testing.T) {
+	h := newMapperHarness(t)
+	h.RunTxTest(t, func(tx *txwrap.TxWrap) {
+		tx.InsertStruct("users", mappedUser{UserId: 1, UserName: "mike", Email: "mike@example.com"})
+		tx.BulkInsert("users", []mappedUser{{UserId: 2, UserName: "sam"}, {UserId: 3, UserName: "alex"}})
+		tx.UpdateStruct("users", mappedUser{UserId: 3, UserName: "alexis"}, []string{"id"})
+		var users []mappedUser
+		tx.Select(&users, `SELECT * FROM users ORDER BY id`)
+		names := make([]string, len(users))
+		for idx, user := range users {
+			names[idx] = user.UserName
+		}
+		if strings.Join(names, ",") != "mike,sam,alexis" {
+			t.Errorf("expected mike,sam,alexis, got %v", names)
+		}
+	})
+}
+
+func TestDBWrapStructFieldsUseDBMapper(t *testing.T) {
+	h := newMapperHarness(t)
+	dbw := txwrap.NewDBWrap(context.Background(), h.DB)
+	defer dbw.Close()
+	dbw.InsertStruct("users", mappedUser{UserId: 1, UserName: "mike"})
+	var user mappedUser
+	dbw.Get(&user, `SELECT * FROM users WHERE id = ?`, 1)
+	if err := dbw.Err(); err != nil {
+		t.Fatalf("DBWrap error: %v", err)
+	}
+	if user.UserName != "mike" {
+		t.Errorf("expected mike, got %q", user.UserName)
+	}
+}
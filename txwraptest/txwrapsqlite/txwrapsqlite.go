@@ -0,0 +1,104 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+// In-memory SQLite harness for integration-style tests of code built on txwrap.
+// Uses the pure Go modernc.org/sqlite driver (no cgo).  This is a separate module
+// so txwrap does not depend on a SQLite driver.
+//
+// Usage:
+//
+//	func TestCreateUser(t *testing.T) {
+//	    h := txwrapsqlite.New(t, "testdata/schema.sql")
+//	    h.RunTxTest(t, func(tx *txwrap.TxWrap) {
+//	        id := repo.CreateUser(tx, "mike")
+//	        ...
+//	    })
+//	}
+package txwrapsqlite
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sawka/txwrap"
+	_ "modernc.org/sqlite"
+)
+
+// DSN for a private in-memory database with foreign keys enabled
+const memoryDSN = "file::memory:?_pragma=foreign_keys(1)"
+
+// returned from the RunTxTest transaction so it is always rolled back
+var errRollbackTest = errors.New("txwrapsqlite, rollback test transaction")
+
+// An in-memory SQLite DB.  The DB is limited to a single connection (each SQLite
+// in-memory connection is a separate database), so while a transaction is running
+// do not make calls directly on DB (they will block until the transaction ends).
+type Harness struct {
+	DB *sqlx.DB
+}
+
+// Creates an in-memory SQLite DB and applies 'schemaFiles' in order (each file is
+// run with TxWrap.ExecScript, so it may contain multiple statements).  The DB is
+// closed when the test finishes.
+func New(t testing.TB, schemaFiles ...string) *Harness {
+	t.Helper()
+	db, err := sqlx.Open("sqlite", memoryDSN)
+	if err != nil {
+		t.Fatalf("txwrapsqlite, cannot open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+	t.Cleanup(func() {
+		db.Close()
+	})
+	h := &Harness{DB: db}
+	for _, fileName := range schemaFiles {
+		script, err := os.ReadFile(fileName)
+		if err != nil {
+			t.Fatalf("txwrapsqlite, cannot read schema file: %v", err)
+		}
+		err = txwrap.WithTx(context.Background(), db, func(tx *txwrap.TxWrap) error {
+			tx.ExecScript(string(script))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("txwrapsqlite, error applying schema file %q: %v", fileName, err)
+		}
+	}
+	return h
+}
+
+// Runs 'fn' in a transaction (with WithTx) that is always rolled back, so every
+// test starts from the same data.  Fails the test if a DB call inside of 'fn' fails
+// (tx.Err is set).
+func (h *Harness) RunTxTest(t testing.TB, fn func(tx *txwrap.TxWrap), opts ...txwrap.TxOption) {
+	t.Helper()
+	err := txwrap.WithTx(context.Background(), h.DB, func(tx *txwrap.TxWrap) error {
+		fn(tx)
+		if tx.Err != nil {
+			return tx.Err
+		}
+		return errRollbackTest
+	}, opts...)
+	if err != nil && !errors.Is(err, errRollbackTest) {
+		t.Errorf("txwrapsqlite, error in test transaction: %v", err)
+	}
+}
+
+// Same as RunTxTest, but the transaction commits (if there are no errors), for
+// setting up data shared by later RunTxTest calls.
+func (h *Harness) RunTx(t testing.TB, fn func(tx *txwrap.TxWrap), opts ...txwrap.TxOption) {
+	t.Helper()
+	err := txwrap.WithTx(context.Background(), h.DB, func(tx *txwrap.TxWrap) error {
+		fn(tx)
+		return nil
+	}, opts...)
+	if err != nil {
+		t.Errorf("txwrapsqlite, error in transaction: %v", err)
+	}
+}
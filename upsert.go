@@ -0,0 +1,116 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// Selects a row with 'selectQuery'.  If there is no row, runs 'insertQuery' and then
+// selects the new row.  Both queries are passed 'args'.  Returns the row and true if
+// it was inserted.
+//
+// The insert runs inside of a savepoint.  If it fails with a unique violation (another
+// transaction inserted the same row concurrently) the error is discarded and the
+// other transaction's row is selected and returned (with false).
+func GetOrInsert[RT any](tx *TxWrap, selectQuery string, insertQuery string, args ...interface{}) (RT, bool) {
+	var rtn RT
+	if tx.get(&rtn, selectQuery, args, false) || tx.Err != nil {
+		return rtn, false
+	}
+	insertErr := WithSavepoint(tx, func() error {
+		tx.Exec(insertQuery, args...)
+		return nil
+	})
+	if insertErr != nil && !IsUniqueViolation(insertErr) {
+		tx.SetErr(insertErr)
+		return rtn, false
+	}
+	tx.get(&rtn, selectQuery, args, true)
+	return rtn, insertErr == nil && tx.Err == nil
+}
+
+// Inserts 'row' (a struct, columns are mapped from its db tags like BulkInsert) into
+// 'table', or updates the existing row if one of 'keyColumns' conflicts.  All non-key
+// columns are updated.  Generates:
+//
+//	Postgres, SQLite: INSERT ... ON CONFLICT (keys) DO UPDATE SET col = excluded.col, ...
+//	MySQL:            INSERT ... ON DUPLICATE KEY UPDATE col = VALUES(col), ...
+//
+// For MySQL the conflict is determined by the table's unique keys, 'keyColumns' are
+// only used to exclude columns from the update.  'table' and the column names are not
+// quoted or escaped, they must not come from user input.
+func (tx *TxWrap) Upsert(table string, keyColumns []string, row interface{}) {
+	if tx.checkErr() {
+		return
+	}
+	query, args, err := makeUpsertQuery(tx.queryer().DriverName(), tx.mapper(), table, keyColumns, row)
+	if err != nil {
+		tx.Err = fmt.Errorf("Upsert %s: %w", table, err)
+		return
+	}
+	tx.Exec(tx.queryer().Rebind(query), args...)
+}
+
+func makeUpsertQuery(driverName string, mapper *reflectx.Mapper, table string, keyColumns []string, row interface{}) (string, []interface{}, error) {
+	if len(keyColumns) == 0 {
+		return "", nil, fmt.Errorf("no key columns")
+	}
+	rowVal, err := structValue(row)
+	if err != nil {
+		return "", nil, err
+	}
+	fields := getStructFields(mapper, rowVal.Type())
+	isKey := make(map[string]bool)
+	for _, col := range keyColumns {
+		isKey[col] = true
+	}
+	hasColumn := make(map[string]bool)
+	var columns, updateCols []string
+	var args []interface{}
+	for _, f := range fields {
+		hasColumn[f.Column] = true
+		columns = append(columns, f.Column)
+		args = append(args, f.value(rowVal))
+		if !isKey[f.Column] {
+			updateCols = append(updateCols, f.Column)
+		}
+	}
+	for _, col := range keyColumns {
+		if !hasColumn[col] {
+			return "", nil, fmt.Errorf("key column %q not found in %v", col, rowVal.Type())
+		}
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), placeholders)
+	var sets []string
+	switch {
+	case strings.Contains(driverName, "mysql"):
+		for _, col := range updateCols {
+			sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", col, col))
+		}
+		if len(sets) == 0 {
+			// no-op update so the duplicate is ignored
+			sets = append(sets, fmt.Sprintf("%s = %s", keyColumns[0], keyColumns[0]))
+		}
+		query += " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	case strings.HasPrefix(driverName, "sqlite") || sqlx.BindType(driverName) == sqlx.DOLLAR:
+		query += fmt.Sprintf(" ON CONFLICT (%s)", strings.Join(keyColumns, ", "))
+		for _, col := range updateCols {
+			sets = append(sets, fmt.Sprintf("%s = excluded.%s", col, col))
+		}
+		if len(sets) == 0 {
+			query += " DO NOTHING"
+		} else {
+			query += " DO UPDATE SET " + strings.Join(sets, ", ")
+		}
+	default:
+		return "", nil, fmt.Errorf("upsert is not supported for driver %q", driverName)
+	}
+	return query, args, nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// converts a scanned uuid column value (string, text []byte, or 16 raw bytes)
+// to canonical form (xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx, lowercase)
+func formatUUID(val interface{}) (string, error) {
+	var raw []byte
+	switch v := val.(type) {
+	case []byte:
+		if len(v) == 16 {
+			raw = v
+		} else {
+			return formatUUID(string(v))
+		}
+	case string:
+		s := strings.TrimSuffix(strings.TrimPrefix(v, "{"), "}")
+		s = strings.ReplaceAll(s, "-", "")
+		if len(s) != 32 {
+			return "", fmt.Errorf("invalid UUID %q", v)
+		}
+		var err error
+		raw, err = hex.DecodeString(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid UUID %q", v)
+		}
+	case [16]byte:
+		raw = v[:]
+	default:
+		return "", fmt.Errorf("invalid UUID type %T", val)
+	}
+	h := hex.EncodeToString(raw)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Set (wrapped) on tx.Err by UpdateVersioned when the row's version no longer
+// matches (another transaction updated it), or the row does not exist.
+var ErrStaleVersion = errors.New("stale version")
+
+// Identifies the row for UpdateVersioned.  Table and column names are not quoted or
+// escaped, they must not come from user input.
+type VersionKey struct {
+	Table         string
+	IdColumn      string // defaults to "id"
+	VersionColumn string // defaults to "version"
+	Id            interface{}
+	Version       int64 // the version that was read
+}
+
+// Optimistic locking update.  Runs:
+//
+//	UPDATE <table> SET <set columns>, version = version + 1 WHERE id = ? AND version = ?
+//
+// 'set' maps column names to new values (columns are set in sorted order).  Returns
+// true if the row was updated.  If no row matched, tx.Err is set to an error wrapping
+// ErrStaleVersion and false is returned.
+func (tx *TxWrap) UpdateVersioned(key VersionKey, set map[string]interface{}) bool {
+	if tx.checkErr() {
+		return false
+	}
+	idCol := key.IdColumn
+	if idCol == "" {
+		idCol = "id"
+	}
+	versionCol := key.VersionColumn
+	if versionCol == "" {
+		versionCol = "version"
+	}
+	cols := make([]string, 0, len(set))
+	for col := range set {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	setClauses := make([]string, 0, len(cols)+1)
+	args := make([]interface{}, 0, len(cols)+2)
+	for _, col := range cols {
+		setClauses = append(setClauses, col+" = ?")
+		args = append(args, set[col])
+	}
+	setClauses = append(setClauses, fmt.Sprintf("%s = %s + 1", versionCol, versionCol))
+	args = append(args, key.Id, key.Version)
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = ? AND %s = ?", key.Table, strings.Join(setClauses, ", "), idCol, versionCol)
+	numRows := tx.ExecAffected(tx.queryer().Rebind(query), args...)
+	if tx.Err != nil {
+		return false
+	}
+	if numRows == 0 {
+		tx.Err = fmt.Errorf("%w: %s %s=%v version %d", ErrStaleVersion, key.Table, idCol, key.Id, key.Version)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+// Copyright 2023-2024 Michael Sawka
+// MIT License (see LICENSE)
+
+package txwrap
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// Passed to the WithWatchdog callback when a transaction runs too long
+type WatchdogInfo struct {
+	Threshold time.Duration
+	StartTs   time.Time
+	Stack     []byte // stack of the goroutine that called WithTx
+}
+
+// Formats the WatchdogInfo as a log message (including the stack)
+func (info WatchdogInfo) String() string {
+	return fmt.Sprintf("transaction running for more than %v (started %s):\n%s", info.Threshold, info.StartTs.Format(time.RFC3339), info.Stack)
+}
+
+// Calls 'fn' if a transaction is still running after 'threshold' (including retries
+// and OnCommit callbacks).  'fn' is called at most once per transaction, from a
+// separate goroutine while the transaction is still running, so it must not use the
+// TxWrap.  The Context is the transaction context.
+//
+// Capturing the stack has a cost for every transaction, so this is intended for
+// thresholds that indicate a real problem (seconds, not milliseconds).
+func WithWatchdog(threshold time.Duration, fn func(ctx context.Context, info WatchdogInfo)) TxOption {
+	return func(opts *txOpts) {
+		// the clock is read when the transaction starts (WithClock may come after this option)
+		opts.txHooks = append(opts.txHooks, func(ctx context.Context) (context.Context, func(TxInfo)) {
+			clock := opts.getClock()
+			info := WatchdogInfo{Threshold: threshold, StartTs: clock.Now(), Stack: debug.Stack()}
+			timer := clock.AfterFunc(threshold, func() {
+				fn(ctx, info)
+			})
+			return ctx, func(TxInfo) {
+				timer.Stop()
+			}
+		})
+	}
+}